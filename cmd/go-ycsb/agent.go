@@ -0,0 +1,193 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pingcap/go-ycsb/pkg/client"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+var (
+	agentCoordinator string
+	agentName        string
+)
+
+func newAgentCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "agent",
+		Short: "Run the share of a benchmark assigned by a coordinator",
+		Long: "Register with a go-ycsb coordinator, run the benchmark it assigns with the properties it sends\n" +
+			"when every agent is ready, and send the measurements back to it.",
+		Args: cobra.NoArgs,
+		Run:  runAgentCommandFunc,
+	}
+	hostname, _ := os.Hostname()
+	m.Flags().StringVar(&agentCoordinator, "coordinator", "http://127.0.0.1:7380", "URL of the coordinator")
+	m.Flags().StringVar(&agentName, "name", fmt.Sprintf("%s-%d", hostname, os.Getpid()), "Name of the agent in the coordinator output")
+	return m
+}
+
+// agentClient calls the coordinator of an agent.
+type agentClient struct {
+	ctx context.Context
+	url string
+	id  int
+}
+
+// call sends req, unless nil, to path of the coordinator and decodes the
+// response into resp, unless nil.
+func (a *agentClient) call(method string, path string, req interface{}, resp interface{}) error {
+	var body io.Reader
+	if req != nil {
+		data, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	httpReq, err := http.NewRequestWithContext(a.ctx, method, a.url+path, body)
+	if err != nil {
+		return err
+	}
+	httpResp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(httpResp.Body)
+		return fmt.Errorf("%s %s: %s %s", method, path, httpResp.Status, strings.TrimSpace(string(msg)))
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+func (a *agentClient) agentPath(path string) string {
+	return fmt.Sprintf("/agents/%d/%s", a.id, path)
+}
+
+// heartbeat sends a heartbeat to the coordinator at every interval until the
+// returned function is called.
+func (a *agentClient) heartbeat(interval time.Duration) func() {
+	if interval <= 0 {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				if err := a.call(http.MethodPost, a.agentPath("heartbeat"), struct{}{}, nil); err != nil {
+					fmt.Printf("heartbeat failed %v\n", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+func runAgentCommandFunc(cmd *cobra.Command, args []string) {
+	a := &agentClient{ctx: globalContext, url: strings.TrimSuffix(agentCoordinator, "/")}
+	var reg registerResponse
+	if err := a.call(http.MethodPost, "/agents", registerRequest{Name: agentName}, &reg); err != nil {
+		util.Fatalf("register with %s failed %v", agentCoordinator, err)
+	}
+	a.id = reg.ID
+	fmt.Printf("Registered as agent %d, waiting for the other agents\n", a.id)
+
+	var as assignment
+	if err := a.call(http.MethodGet, a.agentPath("assignment"), nil, &as); err != nil {
+		util.Fatalf("get assignment failed %v", err)
+	}
+
+	// Set up like a client command with the assigned properties
+	propertyFiles = nil
+	propertyValues = propertyValues[:0]
+	for key, value := range as.Properties {
+		propertyValues = append(propertyValues, key+"="+value)
+	}
+	initialGlobal(as.DB, nil)
+	fmt.Printf("Agent %d of %d, %s %s with insertstart=%s insertcount=%s\n", a.id, as.Agents,
+		globalProps.GetString(prop.Command, ""), as.DB,
+		globalProps.GetString(prop.InsertStart, ""), globalProps.GetString(prop.InsertCount, ""))
+
+	// Send the intervals in order from a goroutine, as the handler must not
+	// block
+	intervals := make(chan intervalRequest, 1024)
+	sent := make(chan error, 1)
+	go func() {
+		var err error
+		for req := range intervals {
+			if err == nil {
+				err = a.call(http.MethodPost, a.agentPath("intervals"), req, nil)
+			}
+		}
+		sent <- err
+	}()
+	var seq int
+	measurement.SetIntervalHandler(func(s *measurement.Snapshot) {
+		intervals <- intervalRequest{Seq: seq, Snapshot: s}
+		seq++
+	})
+
+	c := client.NewClient(globalProps, globalWorkload, globalDB)
+	// The coordinator already starts every agent at the same time
+	c.SetStartDelay(0)
+	var start startResponse
+	if err := a.call(http.MethodPost, a.agentPath("ready"), struct{}{}, &start); err != nil {
+		util.Fatalf("wait for start failed %v", err)
+	}
+	stopHeartbeat := a.heartbeat(as.Heartbeat)
+	time.Sleep(time.Until(start.Start))
+
+	c.Run(globalContext)
+	fmt.Println("**********************************************")
+	fmt.Printf("Run finished, takes %s\n", time.Since(start.Start))
+	measurement.Output()
+
+	measurement.SetIntervalHandler(nil)
+	stopHeartbeat()
+	close(intervals)
+	if err := <-sent; err != nil {
+		util.Fatalf("send intervals failed %v", err)
+	}
+	done := doneRequest{Intervals: seq, Totals: measurement.TotalSnapshot()}
+	if err := a.call(http.MethodPost, a.agentPath("done"), done, nil); err != nil {
+		util.Fatalf("send result failed %v", err)
+	}
+}
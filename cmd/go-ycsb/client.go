@@ -21,6 +21,7 @@ import (
 	"github.com/pingcap/go-ycsb/pkg/client"
 	"github.com/pingcap/go-ycsb/pkg/measurement"
 	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/util"
 	"github.com/spf13/cobra"
 )
 
@@ -28,25 +29,7 @@ func runClientCommandFunc(cmd *cobra.Command, args []string, doTransactions bool
 	dbName := args[0]
 
 	initialGlobal(dbName, func() {
-		doTransFlag := "true"
-		if !doTransactions {
-			doTransFlag = "false"
-		}
-		globalProps.Set(prop.DoTransactions, doTransFlag)
-		globalProps.Set(prop.Command, command)
-
-		if cmd.Flags().Changed("threads") {
-			// We set the threadArg via command line.
-			globalProps.Set(prop.ThreadCount, strconv.Itoa(threadsArg))
-		}
-
-		if cmd.Flags().Changed("target") {
-			globalProps.Set(prop.Target, strconv.Itoa(targetArg))
-		}
-
-		if cmd.Flags().Changed("interval") {
-			globalProps.Set(prop.LogInterval, strconv.Itoa(reportInterval))
-		}
+		setClientProperties(cmd, doTransactions, command)
 	})
 
 	fmt.Println("***************** properties *****************")
@@ -55,12 +38,56 @@ func runClientCommandFunc(cmd *cobra.Command, args []string, doTransactions bool
 	}
 	fmt.Println("**********************************************")
 
-	c := client.NewClient(globalProps, globalWorkload, globalDB)
+	phases, err := client.ParseSchedule(globalProps)
+	if err != nil {
+		util.Fatalf("parse schedule failed %v", err)
+	}
+
 	start := time.Now()
+	if phases != nil {
+		err := client.RunSchedule(globalContext, phases, globalWorkload, globalDB)
+		measurement.Close()
+		if err != nil {
+			util.Fatalf("run schedule failed %v", err)
+		}
+		end := time.Now()
+		fmt.Println("**********************************************")
+		fmt.Printf("Schedule finished, takes %s\n", end.Sub(start))
+		writeResult(dbName, start, end, measurement.Phases())
+		return
+	}
+	c := client.NewClient(globalProps, globalWorkload, globalDB)
 	c.Run(globalContext)
+	end := time.Now()
 	fmt.Println("**********************************************")
-	fmt.Printf("Run finished, takes %s\n", time.Now().Sub(start))
+	fmt.Printf("Run finished, takes %s\n", end.Sub(start))
 	measurement.Output()
+	writeResult(dbName, start, end, measurement.Phases())
+}
+
+// setClientProperties sets the properties of a load or run command, and those
+// set by its flags, in globalProps.
+func setClientProperties(cmd *cobra.Command, doTransactions bool, command string) {
+	doTransFlag := "true"
+	if !doTransactions {
+		doTransFlag = "false"
+	}
+	globalProps.Set(prop.DoTransactions, doTransFlag)
+	globalProps.Set(prop.Command, command)
+
+	if cmd.Flags().Changed("threads") {
+		// We set the threadArg via command line.
+		globalProps.Set(prop.ThreadCount, strconv.Itoa(threadsArg))
+	}
+
+	if cmd.Flags().Changed("target") {
+		globalProps.Set(prop.Target, strconv.Itoa(targetArg))
+	}
+
+	if cmd.Flags().Changed("interval") {
+		// measurement.interval is in ms
+		globalProps.Set(prop.LogInterval, strconv.Itoa(reportInterval*1000))
+	}
 }
 
 func runLoadCommandFunc(cmd *cobra.Command, args []string) {
@@ -0,0 +1,99 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// regressionExitCode is the exit status of compare when a candidate
+// regresses. Results which can't be compared exit with status 1.
+const regressionExitCode = 2
+
+var (
+	compareOptions measurement.CompareOptions
+	compareJSON    bool
+)
+
+func newCompareCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "compare baseline candidate...",
+		Short: "Compare result files and detect regressions",
+		Long: "Compare the measurement.result_file results of candidates with a baseline, operation by operation.\n" +
+			"Each argument is a result file or a directory whose result files are repetitions of one benchmark.\n" +
+			"Exits with status 2 if a gated metric of a candidate regresses by more than the threshold, or if a candidate\n" +
+			"lacks an operation of the baseline, and with status 1 if the results can't be compared.",
+		Args: cobra.MinimumNArgs(2),
+		Run:  runCompareCommandFunc,
+	}
+	o := &compareOptions
+	m.Flags().Float64Var(&o.Threshold, "threshold", 5, "Change in percent beyond which a worse gated metric is a regression")
+	m.Flags().StringSliceVar(&o.Gate, "gate", []string{"ops", "p99", "errors"},
+		"Metrics which can regress, of "+strings.Join(measurement.CompareMetrics(), ", "))
+	m.Flags().Float64Var(&o.Confidence, "confidence", 0.95, "Level of the bootstrap confidence intervals")
+	m.Flags().IntVar(&o.Resamples, "resamples", 10000, "Number of bootstrap resamples")
+	m.Flags().BoolVar(&compareJSON, "json", false, "Output the comparison as JSON")
+	return m
+}
+
+func runCompareCommandFunc(cmd *cobra.Command, args []string) {
+	if compareOptions.Confidence <= 0 || compareOptions.Confidence >= 1 {
+		util.Fatalf("confidence must be between 0 and 1, got %v", compareOptions.Confidence)
+	}
+	if compareOptions.Resamples <= 0 {
+		util.Fatalf("resamples must be positive, got %d", compareOptions.Resamples)
+	}
+	metrics := measurement.CompareMetrics()
+	for _, gate := range compareOptions.Gate {
+		known := false
+		for _, metric := range metrics {
+			known = known || gate == metric
+		}
+		if !known {
+			util.Fatalf("unknown gate metric %s, must be one of %s", gate, strings.Join(metrics, ", "))
+		}
+	}
+
+	sets := make([]*measurement.ResultSet, len(args))
+	for i, path := range args {
+		s, err := measurement.LoadResultSet(path)
+		if err != nil {
+			util.Fatalf("load results %s failed %v", path, err)
+		}
+		sets[i] = s
+	}
+
+	c := measurement.CompareResults(sets[0], sets[1:], compareOptions)
+	if compareJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(c); err != nil {
+			util.Fatalf("encode comparison failed %v", err)
+		}
+	} else {
+		c.WriteText(os.Stdout)
+	}
+
+	if n := c.Regressions(); n > 0 {
+		fmt.Fprintf(os.Stderr, "%d regressions beyond %.1f%%\n", n, compareOptions.Threshold)
+		os.Exit(regressionExitCode)
+	}
+}
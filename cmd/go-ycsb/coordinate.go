@@ -0,0 +1,467 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/client"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// Messages between the coordinator and its agents, sent as JSON over HTTP.
+// An agent registers with POST /agents, waits for its assignment with GET
+// /agents/{id}/assignment, reports it is ready and waits for the start of the
+// run with POST /agents/{id}/ready, then sends every interval to POST
+// /agents/{id}/intervals and the result of the run to POST /agents/{id}/done.
+// During the run it also sends POST /agents/{id}/heartbeat, so that the
+// coordinator notices an agent which stopped.
+type (
+	registerRequest struct {
+		Name string `json:"name"`
+	}
+	registerResponse struct {
+		ID int `json:"id"`
+	}
+	assignment struct {
+		DB         string            `json:"db"`
+		Agents     int               `json:"agents"`
+		Properties map[string]string `json:"properties"`
+		// Heartbeat is the time between the heartbeats of the agent
+		Heartbeat time.Duration `json:"heartbeat"`
+	}
+	startResponse struct {
+		// Start is when every agent starts its run
+		Start time.Time `json:"start"`
+	}
+	intervalRequest struct {
+		Seq      int                   `json:"seq"`
+		Snapshot *measurement.Snapshot `json:"snapshot"`
+	}
+	doneRequest struct {
+		// Intervals is the number of intervals the agent sent
+		Intervals int                   `json:"intervals"`
+		Totals    *measurement.Snapshot `json:"totals"`
+	}
+)
+
+// agentOutputProps are the outputs of a run written by the coordinator from
+// the merged latencies, so agents don't write them.
+var agentOutputProps = []string{
+	prop.MeasurementResultFile,
+	prop.MeasurementTimeSeriesFile,
+	prop.MeasurementHistogramHlogExport,
+}
+
+// agentFileProps are files written by every agent, suffixed with the agent id.
+var agentFileProps = []string{
+	prop.MeasurementRawOutputFile,
+	prop.RecordTrace,
+}
+
+var (
+	coordinateListen       string
+	coordinateAgents       int
+	coordinateStartDelay   time.Duration
+	coordinateAgentTimeout time.Duration
+)
+
+func newCoordinateCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "coordinate load|run db",
+		Short: "Run a benchmark on several agents and merge their measurements",
+		Long: "Wait for --agents go-ycsb agents to register, split the key range, operation count and target\n" +
+			"throughput of the benchmark between them, start them together and merge their measurements.",
+		Args: cobra.ExactArgs(2),
+		Run:  runCoordinateCommandFunc,
+	}
+	initClientCommand(m)
+	m.Flags().StringVar(&coordinateListen, "listen", "0.0.0.0:7380", "Address agents connect to")
+	m.Flags().IntVar(&coordinateAgents, "agents", 1, "Number of agents to run the benchmark on")
+	m.Flags().DurationVar(&coordinateStartDelay, "start-delay", time.Second, "Time between the last agent being ready and the start of the run")
+	m.Flags().DurationVar(&coordinateAgentTimeout, "agent-timeout", 30*time.Second,
+		"Time without a message from an agent during the run after which it is left out, with the intervals it sent")
+	return m
+}
+
+type agentState struct {
+	name      string
+	intervals map[int]*measurement.Snapshot
+	// received are all the intervals sent, which stand in for the totals
+	// of an agent which times out
+	received []*measurement.Snapshot
+	lastSeen time.Time
+	// ready is set once the agent reported it is ready
+	ready bool
+	// done is set when the agent finished, having sent count intervals, or
+	// lost it
+	done  bool
+	count int
+	// lost is set if the agent timed out
+	lost bool
+}
+
+type coordinator struct {
+	sync.Mutex
+
+	db         string
+	parts      []*properties.Properties
+	agents     []*agentState
+	// ready is the number of agents which are ready
+	ready      int
+	start      time.Time
+	startDelay time.Duration
+	// timeout is how long a running agent may go without a message
+	timeout time.Duration
+	// lost is the number of agents which timed out
+	lost int
+
+	registered chan struct{}
+	started    chan struct{}
+	finished   chan struct{}
+	remaining  int
+
+	merger *measurement.Merger
+	// nextInterval is the next interval to report
+	nextInterval int
+	err          error
+}
+
+func runCoordinateCommandFunc(cmd *cobra.Command, args []string) {
+	command, dbName := args[0], args[1]
+	if command != "load" && command != "run" {
+		util.Fatalf("unknown command %s, expected load or run", command)
+	}
+	if coordinateAgents <= 0 {
+		util.Fatalf("agents must be positive, got %d", coordinateAgents)
+	}
+	if coordinateAgentTimeout <= 0 {
+		util.Fatalf("agent timeout must be positive, got %s", coordinateAgentTimeout)
+	}
+
+	loadGlobalProperties(func() {
+		setClientProperties(cmd, command == "run", command)
+	})
+	if len(tableName) > 0 {
+		globalProps.Set(prop.TableName, tableName)
+	}
+	if _, ok := globalProps.Get(prop.Schedule); ok {
+		util.Fatalf("coordinate doesn't support %s", prop.Schedule)
+	}
+
+	c := newCoordinator(dbName, client.Partition(globalProps, coordinateAgents), measurement.NewMerger(globalProps))
+	c.startDelay = coordinateStartDelay
+	c.timeout = coordinateAgentTimeout
+	for i, part := range c.parts {
+		for _, key := range agentOutputProps {
+			part.Delete(key)
+		}
+		for _, key := range agentFileProps {
+			if path, ok := part.Get(key); ok && path != "" {
+				part.Set(key, path+"."+strconv.Itoa(i))
+			}
+		}
+	}
+
+	l, err := net.Listen("tcp", coordinateListen)
+	if err != nil {
+		util.Fatalf("listen on %s failed %v", coordinateListen, err)
+	}
+	go http.Serve(l, c.handler())
+	fmt.Printf("Waiting for %d agents on %s\n", coordinateAgents, l.Addr())
+
+	if err := c.wait(globalContext); err == context.Canceled {
+		util.Fatalf("coordinate canceled")
+	} else if err != nil {
+		util.Fatalf("merge measurements failed %v", err)
+	}
+
+	end := time.Now()
+	fmt.Println("**********************************************")
+	fmt.Printf("Run finished on %d agents, takes %s\n", coordinateAgents, end.Sub(c.start))
+	if c.lost > 0 {
+		fmt.Printf("%d agents timed out, their totals are merged from the intervals they sent\n", c.lost)
+	}
+	phase, err := c.merger.Output(os.Stdout)
+	if err != nil {
+		util.Fatalf("write output failed %v", err)
+	}
+	writeResult(dbName, c.start, end, []measurement.PhaseResult{phase})
+}
+
+func newCoordinator(db string, parts []*properties.Properties, merger *measurement.Merger) *coordinator {
+	return &coordinator{
+		db:         db,
+		parts:      parts,
+		startDelay: time.Second,
+		timeout:    30 * time.Second,
+		registered: make(chan struct{}),
+		started:    make(chan struct{}),
+		finished:   make(chan struct{}),
+		remaining:  len(parts),
+		merger:     merger,
+	}
+}
+
+func (c *coordinator) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /agents", c.handleRegister)
+	mux.HandleFunc("GET /agents/{id}/assignment", c.handleAssignment)
+	mux.HandleFunc("POST /agents/{id}/ready", c.handleReady)
+	mux.HandleFunc("POST /agents/{id}/heartbeat", c.handleHeartbeat)
+	mux.HandleFunc("POST /agents/{id}/intervals", c.handleInterval)
+	mux.HandleFunc("POST /agents/{id}/done", c.handleDone)
+	return mux
+}
+
+// wait blocks until every agent finished or timed out, and returns the
+// error of merging their measurements.
+func (c *coordinator) wait(ctx context.Context) error {
+	t := time.NewTicker(c.timeout / 4)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.finished:
+			c.Lock()
+			defer c.Unlock()
+			return c.err
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			c.expire(time.Now())
+		}
+	}
+}
+
+// expire leaves out the running agents which weren't heard from within the
+// timeout. The intervals they sent are merged instead of their totals.
+func (c *coordinator) expire(now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	select {
+	case <-c.started:
+	default:
+		return
+	}
+
+	for _, a := range c.agents {
+		last := a.lastSeen
+		if last.Before(c.start) {
+			last = c.start
+		}
+		if a.done || now.Sub(last) <= c.timeout {
+			continue
+		}
+		fmt.Printf("Agent %s timed out after %s, merging the %d intervals it sent\n", a.name, now.Sub(last), len(a.received))
+		for _, s := range a.received {
+			if err := c.merger.AddTotals(s); err != nil && c.err == nil {
+				c.err = err
+			}
+		}
+		a.received = nil
+		a.done = true
+		a.lost = true
+		c.lost++
+		c.finish()
+	}
+	c.reportIntervals()
+}
+
+// finish counts an agent as finished.
+func (c *coordinator) finish() {
+	c.remaining--
+	if c.remaining == 0 {
+		close(c.finished)
+	}
+}
+
+// agent returns the id of the agent of the request, or false after replying
+// with an error.
+func (c *coordinator) agent(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	c.Lock()
+	defer c.Unlock()
+	if err != nil || id < 0 || id >= len(c.agents) {
+		http.Error(w, "unknown agent "+r.PathValue("id"), http.StatusNotFound)
+		return 0, false
+	}
+	c.agents[id].lastSeen = time.Now()
+	return id, true
+}
+
+func (c *coordinator) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var req registerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if len(c.agents) == len(c.parts) {
+		http.Error(w, "all agents are registered", http.StatusConflict)
+		return
+	}
+	id := len(c.agents)
+	c.agents = append(c.agents, &agentState{name: req.Name, intervals: make(map[int]*measurement.Snapshot), lastSeen: time.Now()})
+	fmt.Printf("Agent %d (%s) registered from %s\n", id, req.Name, r.RemoteAddr)
+	if len(c.agents) == len(c.parts) {
+		close(c.registered)
+	}
+	writeJSON(w, registerResponse{ID: id})
+}
+
+func (c *coordinator) handleAssignment(w http.ResponseWriter, r *http.Request) {
+	id, ok := c.agent(w, r)
+	if !ok {
+		return
+	}
+	// Properties are assigned once every agent is known
+	select {
+	case <-c.registered:
+	case <-r.Context().Done():
+		return
+	}
+	writeJSON(w, assignment{DB: c.db, Agents: len(c.parts), Properties: c.parts[id].Map(), Heartbeat: c.timeout / 4})
+}
+
+func (c *coordinator) handleReady(w http.ResponseWriter, r *http.Request) {
+	id, ok := c.agent(w, r)
+	if !ok {
+		return
+	}
+	c.Lock()
+	// An agent which asks again, such as after a timeout, is ready once
+	if !c.agents[id].ready {
+		c.agents[id].ready = true
+		c.ready++
+		if c.ready == len(c.parts) {
+			c.start = time.Now().Add(c.startDelay)
+			fmt.Printf("All agents ready, starting at %s\n", c.start.Format(time.RFC3339Nano))
+			close(c.started)
+		}
+	}
+	c.Unlock()
+
+	select {
+	case <-c.started:
+	case <-r.Context().Done():
+		return
+	}
+	writeJSON(w, startResponse{Start: c.start})
+}
+
+func (c *coordinator) handleInterval(w http.ResponseWriter, r *http.Request) {
+	id, ok := c.agent(w, r)
+	if !ok {
+		return
+	}
+	var req intervalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	a := c.agents[id]
+	if a.lost {
+		return
+	}
+	a.intervals[req.Seq] = req.Snapshot
+	a.received = append(a.received, req.Snapshot)
+	c.reportIntervals()
+}
+
+func (c *coordinator) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	c.agent(w, r)
+}
+
+func (c *coordinator) handleDone(w http.ResponseWriter, r *http.Request) {
+	id, ok := c.agent(w, r)
+	if !ok {
+		return
+	}
+	var req doneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	a := c.agents[id]
+	if a.lost {
+		http.Error(w, "agent timed out", http.StatusConflict)
+		return
+	}
+	if a.done {
+		return
+	}
+	a.done = true
+	a.count = req.Intervals
+	a.received = nil
+	if err := c.merger.AddTotals(req.Totals); err != nil && c.err == nil {
+		c.err = err
+	}
+	c.reportIntervals()
+	fmt.Printf("Agent %s finished\n", a.name)
+	c.finish()
+}
+
+// reportIntervals reports the merged intervals which every agent has sent,
+// or has finished before.
+func (c *coordinator) reportIntervals() {
+	for {
+		var snapshots []*measurement.Snapshot
+		for _, a := range c.agents {
+			if s, ok := a.intervals[c.nextInterval]; ok {
+				snapshots = append(snapshots, s)
+				continue
+			}
+			if !a.done || a.count > c.nextInterval {
+				// Wait for the interval of a
+				return
+			}
+		}
+		if len(snapshots) == 0 {
+			return
+		}
+
+		if err := c.merger.Interval(snapshots); err != nil && c.err == nil {
+			c.err = err
+		}
+		for _, a := range c.agents {
+			delete(a.intervals, c.nextInterval)
+		}
+		c.nextInterval++
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
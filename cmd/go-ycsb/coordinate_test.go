@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/client"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+// TestCoordinatorAgents runs a coordinator with two agents over loopback
+// HTTP. One agent finishes, the other stops after its first interval and
+// times out.
+func TestCoordinatorAgents(t *testing.T) {
+	p := properties.LoadMap(map[string]string{
+		prop.RecordCount:    "100",
+		prop.OperationCount: "10",
+	})
+	measurement.InitMeasure(p)
+	for i := 0; i < 10; i++ {
+		measurement.Measure("READ", time.Now(), time.Millisecond)
+	}
+	snapshot := measurement.TotalSnapshot()
+
+	c := newCoordinator("basic", client.Partition(p, 2), measurement.NewMerger(p))
+	c.startDelay = 0
+	c.timeout = 200 * time.Millisecond
+	server := httptest.NewServer(c.handler())
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	waited := make(chan error, 1)
+	go func() {
+		waited <- c.wait(ctx)
+	}()
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func(finish bool) {
+			errs <- runTestAgent(ctx, server.URL, finish, snapshot)
+		}(i == 0)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := <-waited; err != nil {
+		t.Fatal(err)
+	}
+	if c.lost != 1 {
+		t.Fatalf("want 1 agent timed out, got %d", c.lost)
+	}
+	if c.nextInterval != 2 {
+		t.Fatalf("want 2 merged intervals, got %d", c.nextInterval)
+	}
+	phase, err := c.merger.Output(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The totals of the finished agent and the interval of the other one
+	if r := phase.Operations["READ"]; r == nil || r.Count != 20 {
+		t.Fatalf("want 20 merged READ latencies, got %+v", r)
+	}
+
+	// The agent which timed out can't report its result any more
+	a := &agentClient{ctx: ctx, url: server.URL}
+	for id, state := range c.agents {
+		if state.lost {
+			a.id = id
+		}
+	}
+	if err := a.call(http.MethodPost, a.agentPath("done"), doneRequest{Totals: snapshot}, nil); err == nil {
+		t.Fatal("want the result of a timed out agent to be rejected")
+	}
+}
+
+// runTestAgent registers with the coordinator and sends an interval. An
+// agent which finishes sends another interval and its totals, the other one
+// stops.
+func runTestAgent(ctx context.Context, url string, finish bool, snapshot *measurement.Snapshot) error {
+	a := &agentClient{ctx: ctx, url: url}
+	var reg registerResponse
+	if err := a.call(http.MethodPost, "/agents", registerRequest{Name: strconv.FormatBool(finish)}, &reg); err != nil {
+		return err
+	}
+	a.id = reg.ID
+
+	var as assignment
+	if err := a.call(http.MethodGet, a.agentPath("assignment"), nil, &as); err != nil {
+		return err
+	}
+	var start startResponse
+	if err := a.call(http.MethodPost, a.agentPath("ready"), struct{}{}, &start); err != nil {
+		return err
+	}
+	if err := a.call(http.MethodPost, a.agentPath("intervals"), intervalRequest{Seq: 0, Snapshot: snapshot}, nil); err != nil {
+		return err
+	}
+	if !finish {
+		return nil
+	}
+
+	// Send only heartbeats for twice the timeout
+	stopHeartbeat := a.heartbeat(as.Heartbeat)
+	time.Sleep(8 * as.Heartbeat)
+	stopHeartbeat()
+	if err := a.call(http.MethodPost, a.agentPath("intervals"), intervalRequest{Seq: 1, Snapshot: snapshot}, nil); err != nil {
+		return err
+	}
+	return a.call(http.MethodPost, a.agentPath("done"), doneRequest{Intervals: 2, Totals: snapshot}, nil)
+}
+
+// TestCoordinatorReadyOnce retries the ready request of an agent, which must
+// not start the run before the other agent is ready.
+func TestCoordinatorReadyOnce(t *testing.T) {
+	p := properties.LoadMap(map[string]string{prop.RecordCount: "100"})
+	measurement.InitMeasure(p)
+	c := newCoordinator("basic", client.Partition(p, 2), measurement.NewMerger(p))
+	c.startDelay = 0
+	server := httptest.NewServer(c.handler())
+	defer server.Close()
+
+	agents := make([]*agentClient, 2)
+	for i := range agents {
+		agents[i] = &agentClient{ctx: context.Background(), url: server.URL}
+		var reg registerResponse
+		if err := agents[i].call(http.MethodPost, "/agents", registerRequest{Name: strconv.Itoa(i)}, &reg); err != nil {
+			t.Fatal(err)
+		}
+		agents[i].id = reg.ID
+	}
+
+	// The first agent gives up waiting for the start and asks again
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		a := &agentClient{ctx: ctx, url: server.URL, id: agents[0].id}
+		if err := a.call(http.MethodPost, a.agentPath("ready"), struct{}{}, nil); err == nil {
+			t.Fatal("want the run to wait for the second agent")
+		}
+		cancel()
+	}
+	c.Lock()
+	ready := c.ready
+	c.Unlock()
+	if ready != 1 {
+		t.Fatalf("want 1 agent ready, got %d", ready)
+	}
+
+	started := make(chan error, 2)
+	for _, a := range agents {
+		go func(a *agentClient) {
+			var start startResponse
+			started <- a.call(http.MethodPost, a.agentPath("ready"), struct{}{}, &start)
+		}(a)
+	}
+	for range agents {
+		if err := <-started; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/magiconair/properties"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	// Register workload
 
@@ -94,7 +95,9 @@ var (
 	globalProps    *properties.Properties
 )
 
-func initialGlobal(dbName string, onProperties func()) {
+// loadGlobalProperties loads globalProps from the property files and values
+// of the command line.
+func loadGlobalProperties(onProperties func()) {
 	globalProps = properties.NewProperties()
 	if len(propertyFiles) > 0 {
 		globalProps = properties.MustLoadFiles(propertyFiles, properties.UTF8, false)
@@ -111,8 +114,13 @@ func initialGlobal(dbName string, onProperties func()) {
 	if onProperties != nil {
 		onProperties()
 	}
+}
+
+func initialGlobal(dbName string, onProperties func()) {
+	loadGlobalProperties(onProperties)
 
 	addr := globalProps.GetString(prop.DebugPprof, prop.DebugPprofDefault)
+	http.Handle("/metrics", promhttp.Handler())
 	go func() {
 		http.ListenAndServe(addr, nil)
 	}()
@@ -142,7 +150,14 @@ func initialGlobal(dbName string, onProperties func()) {
 	if globalDB, err = dbCreator.Create(globalProps); err != nil {
 		util.Fatalf("create db %s failed %v", dbName, err)
 	}
-	globalDB = client.DbWrapper{globalDB}
+	wrapper := client.DbWrapper{DB: globalDB}
+	if path := globalProps.GetString(prop.RecordTrace, ""); path != "" {
+		format := globalProps.GetString(prop.RecordTraceFormat, prop.RecordTraceFormatDefault)
+		if wrapper.Recorder, err = client.NewTraceRecorder(path, format); err != nil {
+			util.Fatalf("record trace %s failed %v", path, err)
+		}
+	}
+	globalDB = wrapper
 }
 
 func main() {
@@ -183,6 +198,12 @@ func main() {
 		newShellCommand(),
 		newLoadCommand(),
 		newRunCommand(),
+		newTraceStatsCommand(),
+		newTraceConvertCommand(),
+		newMergeHlogCommand(),
+		newCompareCommand(),
+		newCoordinateCommand(),
+		newAgentCommand(),
 	)
 
 	cobra.EnablePrefixMatching = true
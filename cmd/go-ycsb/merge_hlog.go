@@ -0,0 +1,86 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mergeHlogOptions measurement.HlogMergeOptions
+	mergeHlogOutput  string
+)
+
+func newMergeHlogCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "merge-hlog input...",
+		Short: "Merge HdrHistogram interval logs",
+		Long: "Merge HdrHistogram interval logs, such as the histogram.hlog.export logs of one operation\n" +
+			"written by several clients, into one log with intervals of the same length.",
+		Args: cobra.MinimumNArgs(1),
+		Run:  runMergeHlogCommandFunc,
+	}
+	o := &mergeHlogOptions
+	m.Flags().StringVarP(&mergeHlogOutput, "output", "o", "", "Output log (default stdout)")
+	m.Flags().DurationVar(&o.Interval, "interval", 10*time.Second, "Length of the merged intervals")
+	m.Flags().DurationVar(&o.Start, "start", 0, "Drop intervals starting before this long after the earliest interval")
+	m.Flags().DurationVar(&o.End, "end", 0, "Drop intervals starting from this long after the earliest interval (0 = no limit)")
+	return m
+}
+
+func runMergeHlogCommandFunc(cmd *cobra.Command, args []string) {
+	out := os.Stdout
+	if mergeHlogOutput != "" {
+		f, err := os.Create(mergeHlogOutput)
+		if err != nil {
+			util.Fatalf("create %s failed %v", mergeHlogOutput, err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	res, err := measurement.MergeHistogramLogs(args, out, mergeHlogOptions)
+	if err != nil {
+		util.Fatalf("merge interval logs failed %v", err)
+	}
+	// Keep the summary out of a log written to stdout
+	summary := os.Stdout
+	if mergeHlogOutput == "" {
+		summary = os.Stderr
+	}
+
+	fmt.Fprintf(summary, "Merged %d intervals into %d intervals\n", res.Read, res.Written)
+	tags := make([]string, 0, len(res.Totals))
+	for tag := range res.Totals {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		h := res.Totals[tag]
+		name := tag
+		if name == "" {
+			name = "total"
+		}
+		fmt.Fprintf(summary, "%s - Count: %d, Avg(us): %d, Min(us): %d, Max(us): %d, 50th(us): %d, 90th(us): %d, 99th(us): %d, 99.9th(us): %d\n",
+			name, h.TotalCount(), int64(h.Mean()), h.Min(), h.Max(), h.ValueAtPercentile(50), h.ValueAtPercentile(90),
+			h.ValueAtPercentile(99), h.ValueAtPercentile(99.9))
+	}
+}
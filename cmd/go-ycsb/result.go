@@ -0,0 +1,96 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/util"
+	"github.com/pingcap/go-ycsb/pkg/ycsb"
+)
+
+// writeResult writes the result of the run from start to end, with the
+// measurements of phases, to measurement.result_file if set.
+func writeResult(dbName string, start time.Time, end time.Time, phases []measurement.PhaseResult) {
+	path := globalProps.GetString(prop.MeasurementResultFile, "")
+	if path == "" {
+		return
+	}
+
+	hostname, _ := os.Hostname()
+	r := &measurement.Result{
+		Version:  measurement.ResultVersion,
+		Command:  globalProps.GetString(prop.Command, ""),
+		DB:       dbName,
+		Workload: globalProps.GetString(prop.Workload, "core"),
+		Revision: buildRevision(),
+		Host: measurement.Host{
+			Hostname:  hostname,
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+			CPUs:      runtime.NumCPU(),
+			GoVersion: runtime.Version(),
+		},
+		Start:      start,
+		End:        end,
+		Properties: globalProps.Map(),
+		Phases:     phases,
+	}
+
+	if statsDB, ok := globalDB.(ycsb.StatsDB); ok {
+		// The run context may be canceled already, as on a signal
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		stats, err := statsDB.Stats(ctx)
+		cancel()
+		if err != nil {
+			fmt.Printf("Failed to get server stats: %v\n", err)
+		}
+		r.ServerStats = stats
+	}
+
+	if err := measurement.WriteResult(path, r); err != nil {
+		util.Fatalf("write result %s failed %v", path, err)
+	}
+	fmt.Printf("Result written to %s\n", path)
+}
+
+// buildRevision returns the VCS revision of the binary, with a "-dirty"
+// suffix if it was built from a modified tree.
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision string
+	var modified bool
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if revision != "" && modified {
+		revision += "-dirty"
+	}
+	return revision
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/go-ycsb/pkg/util"
+	"github.com/pingcap/go-ycsb/pkg/workload"
+	"github.com/spf13/cobra"
+)
+
+var traceConvertOptions workload.TraceConvertOptions
+
+func newTraceConvertCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "trace-convert input output",
+		Short: "Filter, sample and convert a trace file",
+		Long: "Filter, sample and convert a trace file. The output is zstd compressed if its name ends in .zst,\n" +
+			"and can be replayed by the trace workload in either format.",
+		Args: cobra.ExactArgs(2),
+		Run:  runTraceConvertCommandFunc,
+	}
+	o := &traceConvertOptions
+	m.Flags().StringVar(&o.InputFormat, "input-format", workload.TraceFormatAuto, "Input trace format, like trace.format")
+	m.Flags().StringVar(&o.Format, "format", workload.TraceOutputCSV, "Output format: csv (twemcache CSV) or binary (compact, faster to load)")
+	m.Flags().Int64Var(&o.MaxRecords, "maxrecords", 0, "Only read the first n records (0 = all)")
+	m.Flags().Float64Var(&o.Start, "start", 0, "Drop records before this many seconds after the first record")
+	m.Flags().Float64Var(&o.End, "end", 0, "Drop records from this many seconds after the first record (0 = no limit)")
+	m.Flags().Float64Var(&o.SampleRate, "sample", 1, "Keep this fraction of the keys, selected by key hash so every access to a kept key is kept")
+	m.Flags().StringSliceVar(&o.Operations, "ops", nil, "Only keep these operations, e.g. get,set")
+	m.Flags().StringSliceVar(&o.Clients, "clients", nil, "Only keep these client ids")
+	m.Flags().BoolVar(&o.RenameKeys, "rename-keys", false, "Replace keys with short sequential names")
+	return m
+}
+
+func runTraceConvertCommandFunc(cmd *cobra.Command, args []string) {
+	o := traceConvertOptions
+	for i, op := range o.Operations {
+		o.Operations[i] = strings.ToLower(op)
+	}
+	if o.SampleRate <= 0 || o.SampleRate > 1 {
+		util.Fatalf("invalid sample rate %v, must be in (0, 1]", o.SampleRate)
+	}
+
+	res, err := workload.ConvertTrace(args[0], args[1], o)
+	if err != nil {
+		util.Fatalf("convert trace %s failed %v", args[0], err)
+	}
+	fmt.Printf("Read %d records, wrote %d records with %d unique keys to %s\n", res.Read, res.Written, res.Keys, args[1])
+}
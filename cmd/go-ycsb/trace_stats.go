@@ -0,0 +1,70 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/pingcap/go-ycsb/pkg/util"
+	"github.com/pingcap/go-ycsb/pkg/workload"
+	"github.com/spf13/cobra"
+)
+
+var (
+	traceStatsFormat     string
+	traceStatsJSON       bool
+	traceStatsMaxRecords int64
+	traceStatsWindow     float64
+	traceStatsReuse      bool
+	traceStatsTopClients int
+)
+
+func newTraceStatsCommand() *cobra.Command {
+	m := &cobra.Command{
+		Use:   "trace-stats file",
+		Short: "Characterise a trace file as the trace workload replays it",
+		Args:  cobra.ExactArgs(1),
+		Run:   runTraceStatsCommandFunc,
+	}
+	m.Flags().StringVar(&traceStatsFormat, "input-format", workload.TraceFormatAuto, "Trace file format, like trace.format")
+	m.Flags().BoolVar(&traceStatsJSON, "json", false, "Output the statistics as JSON")
+	m.Flags().Int64Var(&traceStatsMaxRecords, "maxrecords", 0, "Only read the first n records, like trace.maxrecords (0 = all)")
+	m.Flags().Float64Var(&traceStatsWindow, "window", 60, "Length of the working set windows in seconds")
+	m.Flags().BoolVar(&traceStatsReuse, "reuse", true, "Compute the reuse distance distribution (needs 4 bytes of memory per record)")
+	m.Flags().IntVar(&traceStatsTopClients, "top", 20, "Number of busiest clients to list in text output (0 = all)")
+	return m
+}
+
+func runTraceStatsCommandFunc(cmd *cobra.Command, args []string) {
+	stats, err := workload.CollectTraceStats(args[0], workload.TraceStatsOptions{
+		InputFormat:   traceStatsFormat,
+		MaxRecords:    traceStatsMaxRecords,
+		Window:        traceStatsWindow,
+		ReuseDistance: traceStatsReuse,
+	})
+	if err != nil {
+		util.Fatalf("read trace %s failed %v", args[0], err)
+	}
+
+	if !traceStatsJSON {
+		stats.WriteText(os.Stdout, traceStatsTopClients)
+		return
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(stats); err != nil {
+		util.Fatalf("encode trace stats failed %v", err)
+	}
+}
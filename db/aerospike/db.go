@@ -3,6 +3,7 @@ package aerospike
 import (
 	"context"
 	"errors"
+	"time"
 
 	as "github.com/aerospike/aerospike-client-go"
 	"github.com/magiconair/properties"
@@ -109,6 +110,21 @@ func (adb *aerospikedb) Scan(ctx context.Context, table string, startKey string,
 // key: The record key of the record to update.
 // values: A map of field/value pairs to update in the record.
 func (adb *aerospikedb) Update(ctx context.Context, table string, key string, values map[string][]byte) error {
+	return adb.update(ctx, table, key, values, 0)
+}
+
+// UpdateWithTTL updates a record in the database and sets it to expire after ttl.
+// table: The name of the table.
+// key: The record key of the record to update.
+// values: A map of field/value pairs to update in the record.
+// ttl: The time after which the record expires.
+func (adb *aerospikedb) UpdateWithTTL(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) error {
+	return adb.update(ctx, table, key, values, ttlSeconds(ttl))
+}
+
+// update merges values into the record. expiration is the record TTL in
+// seconds, 0 keeps the namespace default.
+func (adb *aerospikedb) update(ctx context.Context, table string, key string, values map[string][]byte, expiration uint32) error {
 	asKey, err := as.NewKey(adb.ns, table, key)
 	if err != nil {
 		return err
@@ -121,8 +137,9 @@ func (adb *aerospikedb) Update(ctx context.Context, table string, key string, va
 	var policy *as.WritePolicy
 	if record != nil {
 		bins = record.Bins
-		policy := as.NewWritePolicy(record.Generation, 0)
-		policy.GenerationPolicy = as.EXPECT_GEN_EQUAL
+	}
+	if expiration > 0 {
+		policy = as.NewWritePolicy(0, expiration)
 	}
 	for k, v := range values {
 		bins[k] = v
@@ -130,12 +147,31 @@ func (adb *aerospikedb) Update(ctx context.Context, table string, key string, va
 	return adb.client.Put(policy, asKey, bins)
 }
 
+// ttlSeconds converts ttl to an aerospike expiration, rounding up so that
+// sub-second TTLs don't fall back to the namespace default.
+func ttlSeconds(ttl time.Duration) uint32 {
+	return uint32((ttl + time.Second - 1) / time.Second)
+}
+
 // Insert inserts a record in the database. Any field/value pairs will be written into the
 // database.
 // table: The name of the table.
 // key: The record key of the record to insert.
 // values: A map of field/value pairs to insert in the record.
 func (adb *aerospikedb) Insert(ctx context.Context, table string, key string, values map[string][]byte) error {
+	return adb.insert(ctx, table, key, values, nil)
+}
+
+// InsertWithTTL inserts a record in the database which expires after ttl.
+// table: The name of the table.
+// key: The record key of the record to insert.
+// values: A map of field/value pairs to insert in the record.
+// ttl: The time after which the record expires.
+func (adb *aerospikedb) InsertWithTTL(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) error {
+	return adb.insert(ctx, table, key, values, as.NewWritePolicy(0, ttlSeconds(ttl)))
+}
+
+func (adb *aerospikedb) insert(ctx context.Context, table string, key string, values map[string][]byte, policy *as.WritePolicy) error {
 	asKey, err := as.NewKey(adb.ns, table, key)
 	if err != nil {
 		return err
@@ -146,7 +182,7 @@ func (adb *aerospikedb) Insert(ctx context.Context, table string, key string, va
 		bins[i] = as.NewBin(k, v)
 		i++
 	}
-	return adb.client.PutBins(nil, asKey, bins...)
+	return adb.client.PutBins(policy, asKey, bins...)
 }
 
 // Delete deletes a record from the database.
@@ -17,6 +17,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/dgraph-io/badger"
 	"github.com/dgraph-io/badger/options"
@@ -196,6 +197,15 @@ func (db *badgerDB) Scan(ctx context.Context, table string, startKey string, cou
 }
 
 func (db *badgerDB) Update(ctx context.Context, table string, key string, values map[string][]byte) error {
+	return db.update(table, key, values, 0)
+}
+
+// UpdateWithTTL implements the ycsb.TTLDB interface.
+func (db *badgerDB) UpdateWithTTL(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) error {
+	return db.update(table, key, values, ttl)
+}
+
+func (db *badgerDB) update(table string, key string, values map[string][]byte, ttl time.Duration) error {
 	err := db.db.Update(func(txn *badger.Txn) error {
 		rowKey := db.getRowKey(table, key)
 		item, err := txn.Get(rowKey)
@@ -226,12 +236,29 @@ func (db *badgerDB) Update(ctx context.Context, table string, key string, values
 		if err != nil {
 			return err
 		}
-		return txn.Set(rowKey, buf)
+		return setWithTTL(txn, rowKey, buf, ttl)
 	})
 	return err
 }
 
+// setWithTTL sets the key in txn, expiring it after ttl if ttl > 0.
+func setWithTTL(txn *badger.Txn, key []byte, value []byte, ttl time.Duration) error {
+	if ttl > 0 {
+		return txn.SetWithTTL(key, value, ttl)
+	}
+	return txn.Set(key, value)
+}
+
 func (db *badgerDB) Insert(ctx context.Context, table string, key string, values map[string][]byte) error {
+	return db.insert(table, key, values, 0)
+}
+
+// InsertWithTTL implements the ycsb.TTLDB interface.
+func (db *badgerDB) InsertWithTTL(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) error {
+	return db.insert(table, key, values, ttl)
+}
+
+func (db *badgerDB) insert(table string, key string, values map[string][]byte, ttl time.Duration) error {
 	err := db.db.Update(func(txn *badger.Txn) error {
 		rowKey := db.getRowKey(table, key)
 
@@ -244,7 +271,7 @@ func (db *badgerDB) Insert(ctx context.Context, table string, key string, values
 		if err != nil {
 			return err
 		}
-		return txn.Set(rowKey, buf)
+		return setWithTTL(txn, rowKey, buf, ttl)
 	})
 
 	return err
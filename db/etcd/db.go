@@ -5,8 +5,10 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
@@ -14,6 +16,7 @@ import (
 	"github.com/magiconair/properties"
 	"go.etcd.io/etcd/client/pkg/v3/transport"
 
+	"github.com/pingcap/go-ycsb/pkg/util"
 	"github.com/pingcap/go-ycsb/pkg/ycsb"
 )
 
@@ -32,8 +35,23 @@ type etcdCreator struct{}
 type etcdDB struct {
 	p      *properties.Properties
 	client *clientv3.Client
+
+	// leases are shared by the TTL writes of the same TTL, by seconds
+	leaseMu sync.Mutex
+	leases  map[int64]cachedLease
+}
+
+// cachedLease is a lease granted for the writes of one TTL.
+type cachedLease struct {
+	id      clientv3.LeaseID
+	granted time.Time
 }
 
+// leaseReuse is how long a lease is shared after it was granted. Leases are
+// granted for leaseReuse longer than the TTL, so a key expires between ttl
+// and ttl + leaseReuse after it was written.
+const leaseReuse = time.Second
+
 func init() {
 	ycsb.RegisterDBCreator("etcd", etcdCreator{})
 }
@@ -52,6 +70,7 @@ func (c etcdCreator) Create(p *properties.Properties) (ycsb.DB, error) {
 	return &etcdDB{
 		p:      p,
 		client: client,
+		leases: make(map[int64]cachedLease),
 	}, nil
 }
 
@@ -163,6 +182,45 @@ func (db *etcdDB) Insert(ctx context.Context, table string, key string, values m
 	return db.Update(ctx, table, key, values)
 }
 
+// UpdateWithTTL attaches the record to a lease which expires after ttl.
+// etcd rounds the TTL up to whole seconds and to its minimum lease TTL.
+func (db *etcdDB) UpdateWithTTL(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) error {
+	rkey := getRowKey(table, key)
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	lease, err := db.lease(ctx, int64((ttl+time.Second-1)/time.Second))
+	if err != nil {
+		return err
+	}
+	_, err = db.client.Put(ctx, rkey, string(data), clientv3.WithLease(lease))
+	return err
+}
+
+// lease returns a lease for writes with a TTL of seconds, granting a new one
+// when the cached lease is older than leaseReuse.
+func (db *etcdDB) lease(ctx context.Context, seconds int64) (clientv3.LeaseID, error) {
+	db.leaseMu.Lock()
+	defer db.leaseMu.Unlock()
+	if l, ok := db.leases[seconds]; ok && time.Since(l.granted) < leaseReuse {
+		return l.id, nil
+	}
+
+	granted := time.Now()
+	resp, err := db.client.Grant(ctx, seconds+int64(leaseReuse/time.Second))
+	if err != nil {
+		return 0, err
+	}
+	db.leases[seconds] = cachedLease{id: resp.ID, granted: granted}
+	return resp.ID, nil
+}
+
+func (db *etcdDB) InsertWithTTL(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) error {
+	return db.UpdateWithTTL(ctx, table, key, values, ttl)
+}
+
 func (db *etcdDB) Delete(ctx context.Context, table string, key string) error {
 	_, err := db.client.Delete(ctx, getRowKey(table, key))
 	if err != nil {
@@ -170,3 +228,89 @@ func (db *etcdDB) Delete(ctx context.Context, table string, key string) error {
 	}
 	return nil
 }
+
+// Increment implements the ycsb.AtomicCounterDB interface with a
+// compare-and-swap transaction on the revision of the record.
+func (db *etcdDB) Increment(ctx context.Context, table string, key string, field string, delta int64) (int64, error) {
+	var n int64
+	err := db.modifyField(ctx, table, key, field, func(old []byte) ([]byte, error) {
+		value, v, err := util.IncrementCounter(old, delta)
+		n = v
+		return value, err
+	})
+	return n, err
+}
+
+// Append implements the ycsb.AppendDB interface.
+func (db *etcdDB) Append(ctx context.Context, table string, key string, field string, data []byte) error {
+	return db.modifyField(ctx, table, key, field, func(old []byte) ([]byte, error) {
+		return append(old, data...), nil
+	})
+}
+
+// Prepend implements the ycsb.AppendDB interface.
+func (db *etcdDB) Prepend(ctx context.Context, table string, key string, field string, data []byte) error {
+	return db.modifyField(ctx, table, key, field, func(old []byte) ([]byte, error) {
+		return append(append([]byte{}, data...), old...), nil
+	})
+}
+
+// errValueChanged stops modifyField when a compare-and-swap doesn't match.
+var errValueChanged = errors.New("value changed")
+
+// CompareAndSwap implements the ycsb.CompareAndSwapDB interface.
+func (db *etcdDB) CompareAndSwap(ctx context.Context, table string, key string, field string, expected []byte, value []byte) (bool, error) {
+	err := db.modifyField(ctx, table, key, field, func(old []byte) ([]byte, error) {
+		if !bytes.Equal(old, expected) {
+			return nil, errValueChanged
+		}
+		return value, nil
+	})
+	if err == errValueChanged {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// modifyField rewrites one field of a record with a transaction that only
+// commits if the record hasn't changed since it was read, retrying on
+// conflicts. The lease of the record is kept.
+func (db *etcdDB) modifyField(ctx context.Context, table string, key string, field string, modify func([]byte) ([]byte, error)) error {
+	rkey := getRowKey(table, key)
+	for {
+		resp, err := db.client.Get(ctx, rkey)
+		if err != nil {
+			return err
+		}
+
+		r := make(map[string][]byte)
+		cmp := clientv3.Compare(clientv3.CreateRevision(rkey), "=", 0)
+		if resp.Count > 0 {
+			kv := resp.Kvs[0]
+			if err = json.Unmarshal(kv.Value, &r); err != nil {
+				return err
+			}
+			cmp = clientv3.Compare(clientv3.ModRevision(rkey), "=", kv.ModRevision)
+		}
+
+		if r[field], err = modify(r[field]); err != nil {
+			return err
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		put := clientv3.OpPut(rkey, string(data))
+		if resp.Count > 0 {
+			put = clientv3.OpPut(rkey, string(data), clientv3.WithIgnoreLease())
+		}
+
+		txn, err := db.client.Txn(ctx).If(cmp).Then(put).Commit()
+		if err != nil {
+			return err
+		}
+		if txn.Succeeded {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package raft
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/magiconair/properties"
+
+	"github.com/pingcap/go-ycsb/pkg/util"
+)
+
+// Encodings of raft.encoding
+const (
+	encodingJSON = "json"
+	encodingRaw  = "raw"
+	encodingRow  = "row"
+)
+
+// rawField is the field a raw value is read as, unless a single field is
+// asked for.
+const rawField = "field0"
+
+// rowTag is the first byte of row values, which a JSON value can't start
+// with.
+const rowTag byte = 2
+
+// valueCodec encodes rows into the values sent to the store, and decodes
+// values of any encoding. Raw values are sent verbatim, so a raw value which
+// is a JSON object or a tagged row is read as one.
+type valueCodec struct {
+	encoding string
+	row      *util.RowCodec
+}
+
+func newValueCodec(p *properties.Properties) (*valueCodec, error) {
+	encoding := p.GetString(raftEncoding, encodingJSON)
+	switch encoding {
+	case encodingJSON, encodingRaw, encodingRow:
+	default:
+		return nil, fmt.Errorf("unknown raft encoding %s", encoding)
+	}
+	return &valueCodec{encoding: encoding, row: util.NewRowCodec(p)}, nil
+}
+
+func (c *valueCodec) encode(values map[string][]byte) (string, error) {
+	switch c.encoding {
+	case encodingRaw:
+		if len(values) != 1 {
+			return "", fmt.Errorf("raw encoding needs a single field, got %d", len(values))
+		}
+		for _, v := range values {
+			return string(v), nil
+		}
+	case encodingRow:
+		data, err := c.row.Encode(nil, values)
+		if err != nil {
+			return "", err
+		}
+		return string(rowTag) + string(data), nil
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decode decodes a value written with any encoding. A value is decoded as a
+// row if it has the row tag and as JSON if it is a JSON object. Any other
+// value is raw, such as one written by another client.
+func (c *valueCodec) decode(value string, fields []string) (map[string][]byte, error) {
+	if len(value) > 0 {
+		switch value[0] {
+		case rowTag:
+			if result, err := c.row.Decode([]byte(value[1:]), fields); err == nil {
+				return result, nil
+			}
+		case '{':
+			var result map[string][]byte
+			if err := json.Unmarshal([]byte(value), &result); err == nil {
+				return result, nil
+			}
+		}
+	}
+
+	field := rawField
+	if len(fields) == 1 {
+		field = fields[0]
+	}
+	return map[string][]byte{field: []byte(value)}, nil
+}
@@ -0,0 +1,86 @@
+package raft
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/magiconair/properties"
+)
+
+func newTestCodec(t *testing.T, encoding string) *valueCodec {
+	c, err := newValueCodec(properties.LoadMap(map[string]string{raftEncoding: encoding}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestValueCodecRoundTrip(t *testing.T) {
+	tests := []struct {
+		encoding string
+		values   map[string][]byte
+		fields   []string
+	}{
+		{encodingJSON, map[string][]byte{"field0": []byte("a"), "field1": []byte("b")}, nil},
+		{encodingRow, map[string][]byte{"field0": []byte("a"), "field1": []byte("b")}, nil},
+		{encodingRow, map[string][]byte{"field1": []byte("b")}, []string{"field1"}},
+		{encodingRaw, map[string][]byte{"field0": []byte("a")}, nil},
+		{encodingRaw, map[string][]byte{"field3": []byte("a")}, []string{"field3"}},
+		// Raw values which start like JSON or a row but aren't are read as raw
+		{encodingRaw, map[string][]byte{"field0": []byte(`{"field1"`)}, nil},
+		{encodingRaw, map[string][]byte{"field0": {8, 0, 2, 2, 'a', 'b'}}, nil},
+		{encodingRaw, map[string][]byte{"field0": {rowTag, 8, 0, 2, 9, 'a', 'b'}}, nil},
+	}
+
+	// Values of any encoding are decoded whatever the encoding of the codec
+	readers := []*valueCodec{newTestCodec(t, encodingJSON), newTestCodec(t, encodingRaw), newTestCodec(t, encodingRow)}
+	for _, tt := range tests {
+		value, err := newTestCodec(t, tt.encoding).encode(tt.values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range readers {
+			got, err := r.decode(value, tt.fields)
+			if err != nil {
+				t.Fatalf("%s value %q: %v", tt.encoding, value, err)
+			}
+			if !reflect.DeepEqual(got, tt.values) {
+				t.Errorf("%s value %q: want %q, got %q", tt.encoding, value, tt.values, got)
+			}
+		}
+	}
+
+	raw, err := newTestCodec(t, encodingRaw).encode(map[string][]byte{"field0": []byte("a")})
+	if err != nil || raw != "a" {
+		t.Errorf("want the raw value verbatim, got %q (%v)", raw, err)
+	}
+	if _, err := newTestCodec(t, encodingRaw).encode(map[string][]byte{"field0": nil, "field1": nil}); err == nil {
+		t.Error("want an error for a raw value of two fields")
+	}
+}
+
+func TestValueCodecDecode(t *testing.T) {
+	c := newTestCodec(t, encodingJSON)
+	tests := []struct {
+		value string
+		want  map[string][]byte
+	}{
+		{`{"field1":"YQ=="}`, map[string][]byte{"field1": []byte("a")}},
+		{`{not json`, map[string][]byte{"field0": []byte(`{not json`)}},
+		{"\x08\x00\x02\x02ab", map[string][]byte{"field0": []byte("\x08\x00\x02\x02ab")}},
+		{"plain", map[string][]byte{"field0": []byte("plain")}},
+		{"", map[string][]byte{"field0": {}}},
+		{"\x02\x08", map[string][]byte{"field0": []byte("\x02\x08")}},
+		// Verbatim raw values can't be told apart from a JSON object or a row
+		{"\x02\x08\x00\x02\x04ab", map[string][]byte{"field0": []byte("ab")}},
+	}
+	for _, tt := range tests {
+		got, err := c.decode(tt.value, nil)
+		if err != nil {
+			t.Fatalf("value %q: %v", tt.value, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("value %q: want %q, got %q", tt.value, tt.want, got)
+		}
+	}
+}
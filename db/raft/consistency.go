@@ -0,0 +1,66 @@
+package raft
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/pingcap/go-ycsb/db/raft/raftapi"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+)
+
+// parseReadConsistency parses raft.read_consistency, a comma-separated list
+// of linearizable, lease and stale.
+func parseReadConsistency(s string) ([]raftapi.ReadConsistency, error) {
+	var levels []raftapi.ReadConsistency
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		v, ok := raftapi.ReadConsistency_value[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown raft read consistency %s", name)
+		}
+		levels = append(levels, raftapi.ReadConsistency(v))
+	}
+	if len(levels) == 0 {
+		return nil, fmt.Errorf("no raft read consistency in %s", raftReadConsistency)
+	}
+	return levels, nil
+}
+
+// readConsistency returns the consistency of the next read, picked at random
+// if there are several.
+func (db *raftDB) readConsistency() raftapi.ReadConsistency {
+	if len(db.readLevels) == 1 {
+		return db.readLevels[0]
+	}
+	return db.readLevels[rand.Intn(len(db.readLevels))]
+}
+
+// callRead sends a read of consistency level with f. A stale read is served
+// by any node, so it is routed with raft.read_routing instead of sent to the
+// leader.
+func (db *raftDB) callRead(ctx context.Context, level raftapi.ReadConsistency, f func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error) error {
+	if level == raftapi.ReadConsistency_STALE {
+		return db.router.callAny(ctx, f)
+	}
+	return db.router.call(ctx, f)
+}
+
+// measureRead measures the read op started at start as op_LEVEL too, so the
+// latencies of the consistency levels are reported apart. The latency spans
+// the retries of the read, and a read which failed after them is measured as
+// op_LEVEL_ERROR.
+func measureRead(ctx context.Context, op string, level raftapi.ReadConsistency, start time.Time, err error) {
+	op = op + "_" + level.String()
+	if err != nil {
+		op += "_ERROR"
+	}
+	measurement.MeasureContext(ctx, op, "", start, time.Since(start))
+}
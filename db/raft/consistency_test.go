@@ -0,0 +1,86 @@
+package raft
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pingcap/go-ycsb/db/raft/raftapi"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+)
+
+func TestParseReadConsistency(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []raftapi.ReadConsistency
+		err  bool
+	}{
+		{s: "linearizable", want: []raftapi.ReadConsistency{raftapi.ReadConsistency_LINEARIZABLE}},
+		{s: "Lease, stale,", want: []raftapi.ReadConsistency{raftapi.ReadConsistency_LEASE, raftapi.ReadConsistency_STALE}},
+		{s: "", err: true},
+		{s: "strong", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseReadConsistency(tt.s)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: want an error, got %v", tt.s, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", tt.s, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: want %v, got %v", tt.s, tt.want, got)
+		}
+	}
+}
+
+// readResults returns the results measured since the router was created or
+// readResults was last called.
+func readResults() map[string]*measurement.OperationResult {
+	measurement.OutputPhase("reads")
+	phases := measurement.Phases()
+	return phases[len(phases)-1].Operations
+}
+
+func TestReadRouting(t *testing.T) {
+	nodes := []*fakeNode{{}, {}, {}}
+	r := newFakeRouter(t, routeLeader, nodes...)
+	r.leader = 2
+	db := &raftDB{router: r, codec: newTestCodec(t, encodingJSON)}
+	read := func(level raftapi.ReadConsistency) error {
+		db.readLevels = []raftapi.ReadConsistency{level}
+		_, err := db.Read(context.Background(), "t", "k", nil)
+		return err
+	}
+
+	// Stale reads go to the nearest node, the others to the leader
+	for _, level := range []raftapi.ReadConsistency{raftapi.ReadConsistency_STALE, raftapi.ReadConsistency_LINEARIZABLE, raftapi.ReadConsistency_LEASE} {
+		if err := read(level); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if nodes[0].callCount() != 1 || nodes[1].callCount() != 0 || nodes[2].callCount() != 2 {
+		t.Fatalf("want 1 stale read of n0 and 2 reads of n2, got calls %d %d %d",
+			nodes[0].callCount(), nodes[1].callCount(), nodes[2].callCount())
+	}
+	results := readResults()
+	for _, op := range []string{"READ_STALE", "READ_LINEARIZABLE", "READ_LEASE"} {
+		if res := results[op]; res == nil || res.Count != 1 {
+			t.Fatalf("want 1 %s, got %+v", op, res)
+		}
+	}
+
+	// A read which failed after its retries is an error of its level
+	for _, n := range nodes {
+		n.set(errUnavailable, "")
+	}
+	if err := read(raftapi.ReadConsistency_STALE); err == nil {
+		t.Fatal("want the stale read to fail")
+	}
+	if res := readResults()["READ_STALE"]; res == nil || res.Errors != 1 || res.Count != 0 {
+		t.Fatalf("want 1 READ_STALE error, got %+v", res)
+	}
+}
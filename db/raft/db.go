@@ -1,11 +1,11 @@
 package raft
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
+	"math"
 	"runtime/debug"
+	"strings"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
@@ -21,8 +21,15 @@ import (
 
 // Property keys for our raft binding.
 const (
-	raftAddressKey  = "raft.address"
-	raftDialTimeout = "raft.dial_timeout"
+	// raftAddressKey is a comma-separated list of the raft nodes
+	raftAddressKey      = "raft.address"
+	raftDialTimeout     = "raft.dial_timeout"
+	raftRouting         = "raft.routing"
+	raftReadRouting     = "raft.read_routing"
+	raftMaxRetries      = "raft.max_retries"
+	raftRetryBackoff    = "raft.retry_backoff"
+	raftEncoding        = "raft.encoding"
+	raftReadConsistency = "raft.read_consistency"
 )
 
 // raftCreator implements the ycsb.DBCreator interface.
@@ -30,8 +37,10 @@ type raftCreator struct{}
 
 type raftDB struct {
 	p      *properties.Properties
-	client raftapi.RaftKVServiceClient
-	conn   *grpc.ClientConn
+	router *router
+	codec  *valueCodec
+	// readLevels are the consistency levels reads pick from
+	readLevels []raftapi.ReadConsistency
 }
 
 func init() {
@@ -39,32 +48,87 @@ func init() {
 	ycsb.RegisterDBCreator("raft", raftCreator{})
 }
 
-// Create sets up the gRPC connection to our raft-based key–value store.
+// Create sets up the gRPC connections to the nodes of our raft-based
+// key–value store.
 func (c raftCreator) Create(p *properties.Properties) (ycsb.DB, error) {
 	// Read properties for connection.
 	address := p.GetString(raftAddressKey, "localhost:12380")
 	dialTimeoutDuration := p.GetDuration(raftDialTimeout, 2*time.Second)
+	routing := p.GetString(raftRouting, routeLeader)
+	readRouting := p.GetString(raftReadRouting, routeNearest)
+	maxRetries := p.GetInt(raftMaxRetries, 30)
+	backoff := p.GetDuration(raftRetryBackoff, 100*time.Millisecond)
+	codec, err := newValueCodec(p)
+	if err != nil {
+		return nil, err
+	}
+	readLevels, err := parseReadConsistency(p.GetString(raftReadConsistency, "linearizable"))
+	if err != nil {
+		return nil, err
+	}
 
-	// Create a context with timeout.
-	ctx, cancel := context.WithTimeout(context.Background(), dialTimeoutDuration)
-	defer cancel()
+	var nodes []*node
+	reachable := false
+	for _, addr := range strings.Split(address, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		// Establish a gRPC connection. (This example uses insecure connection.)
+		conn, err := grpc.DialContext(context.Background(), addr, grpc.WithInsecure())
+		if err != nil {
+			closeNodes(nodes)
+			return nil, err
+		}
+		n := &node{addr: addr, conn: conn, client: raftapi.NewRaftKVServiceClient(conn)}
+		nodes = append(nodes, n)
 
-	// Establish a gRPC connection. (This example uses insecure connection.)
-	conn, err := grpc.DialContext(ctx, address, grpc.WithInsecure(), grpc.WithBlock())
+		n.probe(dialTimeoutDuration)
+		if n.rtt == math.MaxInt64 {
+			fmt.Printf("raft node %s is unreachable\n", addr)
+		} else {
+			fmt.Printf("raft node %s rtt %s\n", addr, n.rtt)
+			reachable = true
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no raft address in %s", raftAddressKey)
+	}
+	if !reachable {
+		closeNodes(nodes)
+		return nil, fmt.Errorf("no raft node of %s is reachable", address)
+	}
+
+	r, err := newRouter(nodes, routing, readRouting, maxRetries, backoff)
 	if err != nil {
+		closeNodes(nodes)
 		return nil, err
 	}
+	db := &raftDB{
+		p:          p,
+		router:     r,
+		codec:      codec,
+		readLevels: readLevels,
+	}
+	if codec.encoding == encodingJSON {
+		return &jsonRaftDB{db}, nil
+	}
+	return db, nil
+}
 
-	client := raftapi.NewRaftKVServiceClient(conn)
-	return &raftDB{
-		p:      p,
-		client: client,
-		conn:   conn,
-	}, nil
+func closeNodes(nodes []*node) error {
+	var err error
+	for _, n := range nodes {
+		if e := n.conn.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
 }
 
 func (db *raftDB) Close() error {
-	return db.conn.Close()
+	db.router.close()
+	return closeNodes(db.router.nodes)
 }
 
 // InitThread and CleanupThread are no-ops for this binding.
@@ -79,12 +143,20 @@ func getRowKey(table string, key string) string {
 	return fmt.Sprintf("%s:%s", table, key)
 }
 
-// Read queries the raft store via gRPC Get. It expects the stored value is a JSON
-// encoded map[string][]byte.
+// Read queries the raft store via gRPC Get, with a consistency level of
+// raft.read_consistency, and decodes the stored value of any encoding.
 func (db *raftDB) Read(ctx context.Context, table string, key string, fields []string) (map[string][]byte, error) {
 	rkey := getRowKey(table, key)
-	req := &raftapi.GetRequest{Key: proto.String(key)}
-	resp, err := db.client.Get(ctx, req)
+	level := db.readConsistency()
+	req := &raftapi.GetRequest{Key: proto.String(rkey), Consistency: level.Enum()}
+	var resp *raftapi.GetResponse
+	start := time.Now()
+	err := db.callRead(ctx, level, func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error {
+		var err error
+		resp, err = c.Get(ctx, req, opts...)
+		return err
+	})
+	measureRead(ctx, "READ", level, start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -94,35 +166,82 @@ func (db *raftDB) Read(ctx context.Context, table string, key string, fields []s
 		return nil, fmt.Errorf("could not find value for key [%s]", rkey)
 	}
 
-	// Decode the JSON-encoded value into a map.
-	var result map[string][]byte
-	err = json.NewDecoder(bytes.NewReader([]byte(resp.GetValue()))).Decode(&result)
+	return db.codec.decode(resp.GetValue(), fields)
+}
+
+// Scan reads up to count rows of table from startKey on via the Scan RPC.
+func (db *raftDB) Scan(ctx context.Context, table string, startKey string, count int, fields []string) ([]map[string][]byte, error) {
+	level := db.readConsistency()
+	req := &raftapi.ScanRequest{
+		Start:       proto.String(getRowKey(table, startKey)),
+		Limit:       proto.Uint32(uint32(count)),
+		Consistency: level.Enum(),
+	}
+	var resp *raftapi.ScanResponse
+	start := time.Now()
+	err := db.callRead(ctx, level, func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error {
+		var err error
+		resp, err = c.Scan(ctx, req, opts...)
+		return err
+	})
+	measureRead(ctx, "SCAN", level, start, err)
 	if err != nil {
 		return nil, err
 	}
-	return result, nil
-}
 
-// Scan is not fully supported here; it could be implemented if the underlying
-// store supports range queries
-func (db *raftDB) Scan(ctx context.Context, table string, startKey string, count int, fields []string) ([]map[string][]byte, error) {
-	return nil, fmt.Errorf("scan operation not implemented")
+	prefix := getRowKey(table, "")
+	res := make([]map[string][]byte, 0, len(resp.GetKvs()))
+	for _, kv := range resp.GetKvs() {
+		// The scan may run past the last row of the table
+		if !strings.HasPrefix(kv.GetKey(), prefix) {
+			break
+		}
+		row, err := db.codec.decode(kv.GetValue(), fields)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, row)
+	}
+	return res, nil
 }
 
-// Update encodes the provided values as JSON and sends them via Put RPC.
+// Update encodes the provided values with raft.encoding and sends them via
+// Put RPC.
 func (db *raftDB) Update(ctx context.Context, table string, key string, values map[string][]byte) error {
-	rkey := getRowKey(table, key)
-	data, err := json.Marshal(values)
+	return db.put(ctx, table, key, values, 0)
+}
+
+// UpdateWithTTL is like Update but asks the store to expire the key after ttl.
+func (db *raftDB) UpdateWithTTL(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) error {
+	return db.put(ctx, table, key, values, ttl)
+}
+
+func (db *raftDB) put(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) error {
+	req, err := db.putRequest(table, key, values, ttl)
 	if err != nil {
 		return err
 	}
+	return db.router.call(ctx, func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error {
+		_, err := c.Put(ctx, req, opts...)
+		return err
+	})
+}
+
+// putRequest encodes values with raft.encoding in a PutRequest of the row.
+func (db *raftDB) putRequest(table string, key string, values map[string][]byte, ttl time.Duration) (*raftapi.PutRequest, error) {
+	data, err := db.codec.encode(values)
+	if err != nil {
+		return nil, err
+	}
 
 	req := &raftapi.PutRequest{
-		Key:   proto.String(rkey),
-		Value: proto.String(string(data)),
+		Key:   proto.String(getRowKey(table, key)),
+		Value: proto.String(data),
 	}
-	_, err = db.client.Put(ctx, req)
-	return err
+	if ttl > 0 {
+		req.TtlMs = proto.Uint64(uint64(ttl.Milliseconds()))
+	}
+	return req, nil
 }
 
 // Insert is implemented as an Update.
@@ -130,23 +249,198 @@ func (db *raftDB) Insert(ctx context.Context, table string, key string, values m
 	return db.Update(ctx, table, key, values)
 }
 
-// Delete is simulated by putting an empty JSON object.
-// (Adjust this behavior if your raft service supports a dedicated delete operation.)
+// InsertWithTTL is implemented as an UpdateWithTTL.
+func (db *raftDB) InsertWithTTL(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) error {
+	return db.UpdateWithTTL(ctx, table, key, values, ttl)
+}
+
+// Delete removes the row via the Delete RPC.
 func (db *raftDB) Delete(ctx context.Context, table string, key string) error {
-	rkey := getRowKey(table, key)
-	req := &raftapi.PutRequest{
-		Key:   proto.String(rkey),
-		Value: proto.String("{}"),
+	req := &raftapi.DeleteRequest{Key: proto.String(getRowKey(table, key))}
+	return db.router.call(ctx, func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error {
+		_, err := c.Delete(ctx, req, opts...)
+		return err
+	})
+}
+
+// BatchInsert implements the ycsb.BatchDB interface with one BatchPut RPC.
+func (db *raftDB) BatchInsert(ctx context.Context, table string, keys []string, values []map[string][]byte) error {
+	return db.BatchUpdate(ctx, table, keys, values)
+}
+
+// BatchUpdate implements the ycsb.BatchDB interface with one BatchPut RPC.
+func (db *raftDB) BatchUpdate(ctx context.Context, table string, keys []string, values []map[string][]byte) error {
+	req := &raftapi.BatchPutRequest{Puts: make([]*raftapi.PutRequest, len(keys))}
+	for i, key := range keys {
+		put, err := db.putRequest(table, key, values[i], 0)
+		if err != nil {
+			return err
+		}
+		req.Puts[i] = put
 	}
-	_, err := db.client.Put(ctx, req)
-	return err
+	return db.router.call(ctx, func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error {
+		_, err := c.BatchPut(ctx, req, opts...)
+		return err
+	})
 }
 
-func (db *raftDB) ResetStats(ctx context.Context) error {
-	_, err := db.client.ResetCacheHits(ctx, &raftapi.Empty{})
+// BatchRead implements the ycsb.BatchDB interface with one BatchGet RPC. Rows
+// which are not found are nil.
+func (db *raftDB) BatchRead(ctx context.Context, table string, keys []string, fields []string) ([]map[string][]byte, error) {
+	level := db.readConsistency()
+	req := &raftapi.BatchGetRequest{Keys: make([]string, len(keys)), Consistency: level.Enum()}
+	for i, key := range keys {
+		req.Keys[i] = getRowKey(table, key)
+	}
+	var resp *raftapi.BatchGetResponse
+	start := time.Now()
+	err := db.callRead(ctx, level, func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error {
+		var err error
+		resp, err = c.BatchGet(ctx, req, opts...)
+		return err
+	})
+	measureRead(ctx, "BATCH_READ", level, start, err)
 	if err != nil {
+		return nil, err
+	}
+	if len(resp.GetValues()) != len(keys) {
+		return nil, fmt.Errorf("unexpected number of results, expected %d but was %d", len(keys), len(resp.GetValues()))
+	}
+
+	res := make([]map[string][]byte, len(keys))
+	for i, v := range resp.GetValues() {
+		if !v.GetFound() {
+			continue
+		}
+		if res[i], err = db.codec.decode(v.GetValue(), fields); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// BatchDelete implements the ycsb.BatchDB interface. There is no batch
+// delete RPC, so the rows are deleted one by one.
+func (db *raftDB) BatchDelete(ctx context.Context, table string, keys []string) error {
+	for _, key := range keys {
+		if err := db.Delete(ctx, table, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonRaftDB is the raft binding of the json encoding. The store increments,
+// appends to and compare-and-swaps the fields of JSON values natively, but
+// can't do so in raw and row values, so the client emulates these operations
+// with a read and an update for them.
+type jsonRaftDB struct {
+	*raftDB
+}
+
+// Increment implements the ycsb.AtomicCounterDB interface.
+func (db *jsonRaftDB) Increment(ctx context.Context, table string, key string, field string, delta int64) (int64, error) {
+	req := &raftapi.IncrementRequest{
+		Key:   proto.String(getRowKey(table, key)),
+		Field: proto.String(field),
+		Delta: proto.Int64(delta),
+	}
+	var resp *raftapi.IncrementResponse
+	err := db.router.callOnce(ctx, func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error {
+		var err error
+		resp, err = c.Increment(ctx, req, opts...)
 		return err
+	})
+	if err != nil {
+		return 0, err
 	}
-	_, err = db.client.ResetRestored(ctx, &raftapi.Empty{})
-	return err
+	return resp.GetValue(), nil
+}
+
+// Append implements the ycsb.AppendDB interface.
+func (db *jsonRaftDB) Append(ctx context.Context, table string, key string, field string, data []byte) error {
+	return db.append(ctx, table, key, field, data, false)
+}
+
+// Prepend implements the ycsb.AppendDB interface.
+func (db *jsonRaftDB) Prepend(ctx context.Context, table string, key string, field string, data []byte) error {
+	return db.append(ctx, table, key, field, data, true)
+}
+
+func (db *jsonRaftDB) append(ctx context.Context, table string, key string, field string, data []byte, prepend bool) error {
+	req := &raftapi.AppendRequest{
+		Key:     proto.String(getRowKey(table, key)),
+		Field:   proto.String(field),
+		Value:   data,
+		Prepend: proto.Bool(prepend),
+	}
+	return db.router.callOnce(ctx, func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error {
+		_, err := c.Append(ctx, req, opts...)
+		return err
+	})
+}
+
+// CompareAndSwap implements the ycsb.CompareAndSwapDB interface.
+func (db *jsonRaftDB) CompareAndSwap(ctx context.Context, table string, key string, field string, expected []byte, value []byte) (bool, error) {
+	req := &raftapi.CompareAndSwapRequest{
+		Key:      proto.String(getRowKey(table, key)),
+		Field:    proto.String(field),
+		Expected: expected,
+		Value:    value,
+	}
+	var resp *raftapi.CompareAndSwapResponse
+	err := db.router.callOnce(ctx, func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error {
+		var err error
+		resp, err = c.CompareAndSwap(ctx, req, opts...)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return resp.GetSwapped(), nil
+}
+
+// Stats implements the ycsb.StatsDB interface. The stats are summed over the
+// reachable nodes, and given per node too if there is more than one.
+func (db *raftDB) Stats(ctx context.Context) (map[string]interface{}, error) {
+	stats := make(map[string]interface{})
+	var totalHits, totalRestored uint64
+	var lastErr error
+	for _, n := range db.router.nodes {
+		hits, err := n.client.GetCacheHits(ctx, &raftapi.Empty{})
+		var restored *raftapi.RestoredResponse
+		if err == nil {
+			restored, err = n.client.GetRestored(ctx, &raftapi.Empty{})
+		}
+		if err != nil {
+			// A node may be down after a failover test
+			stats[n.addr+".error"] = err.Error()
+			lastErr = err
+			continue
+		}
+		totalHits += hits.GetCachehits()
+		totalRestored += restored.GetRestored()
+		if len(db.router.nodes) > 1 {
+			stats[n.addr+".cache_hits"] = hits.GetCachehits()
+			stats[n.addr+".restored"] = restored.GetRestored()
+		}
+	}
+	if len(db.router.nodes) == 1 && lastErr != nil {
+		return nil, lastErr
+	}
+	stats["cache_hits"] = totalHits
+	stats["restored"] = totalRestored
+	return stats, nil
+}
+
+func (db *raftDB) ResetStats(ctx context.Context) error {
+	for _, n := range db.router.nodes {
+		if _, err := n.client.ResetCacheHits(ctx, &raftapi.Empty{}); err != nil {
+			return err
+		}
+		if _, err := n.client.ResetRestored(ctx, &raftapi.Empty{}); err != nil {
+			return err
+		}
+	}
+	return nil
 }
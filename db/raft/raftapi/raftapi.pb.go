@@ -21,10 +21,75 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// How up to date a read must be.
+type ReadConsistency int32
+
+const (
+	// Confirmed by a quorum through ReadIndex.
+	ReadConsistency_LINEARIZABLE ReadConsistency = 0
+	// Served by the leader while its lease holds.
+	ReadConsistency_LEASE ReadConsistency = 1
+	// Served from the local state of any node.
+	ReadConsistency_STALE ReadConsistency = 2
+)
+
+// Enum value maps for ReadConsistency.
+var (
+	ReadConsistency_name = map[int32]string{
+		0: "LINEARIZABLE",
+		1: "LEASE",
+		2: "STALE",
+	}
+	ReadConsistency_value = map[string]int32{
+		"LINEARIZABLE": 0,
+		"LEASE":        1,
+		"STALE":        2,
+	}
+)
+
+func (x ReadConsistency) Enum() *ReadConsistency {
+	p := new(ReadConsistency)
+	*p = x
+	return p
+}
+
+func (x ReadConsistency) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ReadConsistency) Descriptor() protoreflect.EnumDescriptor {
+	return file_raftapi_proto_enumTypes[0].Descriptor()
+}
+
+func (ReadConsistency) Type() protoreflect.EnumType {
+	return &file_raftapi_proto_enumTypes[0]
+}
+
+func (x ReadConsistency) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Do not use.
+func (x *ReadConsistency) UnmarshalJSON(b []byte) error {
+	num, err := protoimpl.X.UnmarshalJSONEnum(x.Descriptor(), b)
+	if err != nil {
+		return err
+	}
+	*x = ReadConsistency(num)
+	return nil
+}
+
+// Deprecated: Use ReadConsistency.Descriptor instead.
+func (ReadConsistency) EnumDescriptor() ([]byte, []int) {
+	return file_raftapi_proto_rawDescGZIP(), []int{0}
+}
+
 type PutRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Key           *string                `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
-	Value         *string                `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Key   *string                `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Value *string                `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	// Time to live of the key in milliseconds, unset or 0 means no expiry.
+	TtlMs         *uint64 `protobuf:"varint,3,opt,name=ttl_ms,json=ttlMs" json:"ttl_ms,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -73,6 +138,13 @@ func (x *PutRequest) GetValue() string {
 	return ""
 }
 
+func (x *PutRequest) GetTtlMs() uint64 {
+	if x != nil && x.TtlMs != nil {
+		return *x.TtlMs
+	}
+	return 0
+}
+
 type PutResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Key           *string                `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
@@ -128,6 +200,7 @@ func (x *PutResponse) GetValue() string {
 type GetRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Key           *string                `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Consistency   *ReadConsistency       `protobuf:"varint,2,opt,name=consistency,enum=raftapi.ReadConsistency" json:"consistency,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -169,6 +242,13 @@ func (x *GetRequest) GetKey() string {
 	return ""
 }
 
+func (x *GetRequest) GetConsistency() ReadConsistency {
+	if x != nil && x.Consistency != nil {
+		return *x.Consistency
+	}
+	return ReadConsistency_LINEARIZABLE
+}
+
 type GetResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Found         *bool                  `protobuf:"varint,1,opt,name=found" json:"found,omitempty"`
@@ -221,6 +301,634 @@ func (x *GetResponse) GetValue() string {
 	return ""
 }
 
+type DeleteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           *string                `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRequest) Reset() {
+	*x = DeleteRequest{}
+	mi := &file_raftapi_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRequest) ProtoMessage() {}
+
+func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_raftapi_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return file_raftapi_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DeleteRequest) GetKey() string {
+	if x != nil && x.Key != nil {
+		return *x.Key
+	}
+	return ""
+}
+
+type ScanRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         *string                `protobuf:"bytes,1,opt,name=start" json:"start,omitempty"`
+	Limit         *uint32                `protobuf:"varint,2,opt,name=limit" json:"limit,omitempty"`
+	Consistency   *ReadConsistency       `protobuf:"varint,3,opt,name=consistency,enum=raftapi.ReadConsistency" json:"consistency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScanRequest) Reset() {
+	*x = ScanRequest{}
+	mi := &file_raftapi_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScanRequest) ProtoMessage() {}
+
+func (x *ScanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_raftapi_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScanRequest.ProtoReflect.Descriptor instead.
+func (*ScanRequest) Descriptor() ([]byte, []int) {
+	return file_raftapi_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ScanRequest) GetStart() string {
+	if x != nil && x.Start != nil {
+		return *x.Start
+	}
+	return ""
+}
+
+func (x *ScanRequest) GetLimit() uint32 {
+	if x != nil && x.Limit != nil {
+		return *x.Limit
+	}
+	return 0
+}
+
+func (x *ScanRequest) GetConsistency() ReadConsistency {
+	if x != nil && x.Consistency != nil {
+		return *x.Consistency
+	}
+	return ReadConsistency_LINEARIZABLE
+}
+
+type KeyValue struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           *string                `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Value         *string                `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KeyValue) Reset() {
+	*x = KeyValue{}
+	mi := &file_raftapi_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeyValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyValue) ProtoMessage() {}
+
+func (x *KeyValue) ProtoReflect() protoreflect.Message {
+	mi := &file_raftapi_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyValue.ProtoReflect.Descriptor instead.
+func (*KeyValue) Descriptor() ([]byte, []int) {
+	return file_raftapi_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *KeyValue) GetKey() string {
+	if x != nil && x.Key != nil {
+		return *x.Key
+	}
+	return ""
+}
+
+func (x *KeyValue) GetValue() string {
+	if x != nil && x.Value != nil {
+		return *x.Value
+	}
+	return ""
+}
+
+type ScanResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kvs           []*KeyValue            `protobuf:"bytes,1,rep,name=kvs" json:"kvs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScanResponse) Reset() {
+	*x = ScanResponse{}
+	mi := &file_raftapi_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScanResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScanResponse) ProtoMessage() {}
+
+func (x *ScanResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_raftapi_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScanResponse.ProtoReflect.Descriptor instead.
+func (*ScanResponse) Descriptor() ([]byte, []int) {
+	return file_raftapi_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ScanResponse) GetKvs() []*KeyValue {
+	if x != nil {
+		return x.Kvs
+	}
+	return nil
+}
+
+type BatchPutRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Puts          []*PutRequest          `protobuf:"bytes,1,rep,name=puts" json:"puts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchPutRequest) Reset() {
+	*x = BatchPutRequest{}
+	mi := &file_raftapi_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchPutRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchPutRequest) ProtoMessage() {}
+
+func (x *BatchPutRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_raftapi_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchPutRequest.ProtoReflect.Descriptor instead.
+func (*BatchPutRequest) Descriptor() ([]byte, []int) {
+	return file_raftapi_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *BatchPutRequest) GetPuts() []*PutRequest {
+	if x != nil {
+		return x.Puts
+	}
+	return nil
+}
+
+type BatchGetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Keys          []string               `protobuf:"bytes,1,rep,name=keys" json:"keys,omitempty"`
+	Consistency   *ReadConsistency       `protobuf:"varint,2,opt,name=consistency,enum=raftapi.ReadConsistency" json:"consistency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetRequest) Reset() {
+	*x = BatchGetRequest{}
+	mi := &file_raftapi_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetRequest) ProtoMessage() {}
+
+func (x *BatchGetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_raftapi_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetRequest.ProtoReflect.Descriptor instead.
+func (*BatchGetRequest) Descriptor() ([]byte, []int) {
+	return file_raftapi_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *BatchGetRequest) GetKeys() []string {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+func (x *BatchGetRequest) GetConsistency() ReadConsistency {
+	if x != nil && x.Consistency != nil {
+		return *x.Consistency
+	}
+	return ReadConsistency_LINEARIZABLE
+}
+
+type BatchGetResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []*GetResponse         `protobuf:"bytes,1,rep,name=values" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetResponse) Reset() {
+	*x = BatchGetResponse{}
+	mi := &file_raftapi_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetResponse) ProtoMessage() {}
+
+func (x *BatchGetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_raftapi_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetResponse.ProtoReflect.Descriptor instead.
+func (*BatchGetResponse) Descriptor() ([]byte, []int) {
+	return file_raftapi_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *BatchGetResponse) GetValues() []*GetResponse {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type IncrementRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Key   *string                `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Field *string                `protobuf:"bytes,2,opt,name=field" json:"field,omitempty"`
+	// Amount to add to the decimal counter stored in the field, negative to
+	// decrement.
+	Delta         *int64 `protobuf:"varint,3,opt,name=delta" json:"delta,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IncrementRequest) Reset() {
+	*x = IncrementRequest{}
+	mi := &file_raftapi_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IncrementRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IncrementRequest) ProtoMessage() {}
+
+func (x *IncrementRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_raftapi_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IncrementRequest.ProtoReflect.Descriptor instead.
+func (*IncrementRequest) Descriptor() ([]byte, []int) {
+	return file_raftapi_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *IncrementRequest) GetKey() string {
+	if x != nil && x.Key != nil {
+		return *x.Key
+	}
+	return ""
+}
+
+func (x *IncrementRequest) GetField() string {
+	if x != nil && x.Field != nil {
+		return *x.Field
+	}
+	return ""
+}
+
+func (x *IncrementRequest) GetDelta() int64 {
+	if x != nil && x.Delta != nil {
+		return *x.Delta
+	}
+	return 0
+}
+
+type IncrementResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         *int64                 `protobuf:"varint,1,opt,name=value" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IncrementResponse) Reset() {
+	*x = IncrementResponse{}
+	mi := &file_raftapi_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IncrementResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IncrementResponse) ProtoMessage() {}
+
+func (x *IncrementResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_raftapi_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IncrementResponse.ProtoReflect.Descriptor instead.
+func (*IncrementResponse) Descriptor() ([]byte, []int) {
+	return file_raftapi_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *IncrementResponse) GetValue() int64 {
+	if x != nil && x.Value != nil {
+		return *x.Value
+	}
+	return 0
+}
+
+type AppendRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Key   *string                `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Field *string                `protobuf:"bytes,2,opt,name=field" json:"field,omitempty"`
+	Value []byte                 `protobuf:"bytes,3,opt,name=value" json:"value,omitempty"`
+	// Add value in front of the field instead of after it.
+	Prepend       *bool `protobuf:"varint,4,opt,name=prepend" json:"prepend,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AppendRequest) Reset() {
+	*x = AppendRequest{}
+	mi := &file_raftapi_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AppendRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AppendRequest) ProtoMessage() {}
+
+func (x *AppendRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_raftapi_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AppendRequest.ProtoReflect.Descriptor instead.
+func (*AppendRequest) Descriptor() ([]byte, []int) {
+	return file_raftapi_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *AppendRequest) GetKey() string {
+	if x != nil && x.Key != nil {
+		return *x.Key
+	}
+	return ""
+}
+
+func (x *AppendRequest) GetField() string {
+	if x != nil && x.Field != nil {
+		return *x.Field
+	}
+	return ""
+}
+
+func (x *AppendRequest) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *AppendRequest) GetPrepend() bool {
+	if x != nil && x.Prepend != nil {
+		return *x.Prepend
+	}
+	return false
+}
+
+type CompareAndSwapRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Key   *string                `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Field *string                `protobuf:"bytes,2,opt,name=field" json:"field,omitempty"`
+	// A missing field matches an empty expected value.
+	Expected      []byte `protobuf:"bytes,3,opt,name=expected" json:"expected,omitempty"`
+	Value         []byte `protobuf:"bytes,4,opt,name=value" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompareAndSwapRequest) Reset() {
+	*x = CompareAndSwapRequest{}
+	mi := &file_raftapi_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompareAndSwapRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompareAndSwapRequest) ProtoMessage() {}
+
+func (x *CompareAndSwapRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_raftapi_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompareAndSwapRequest.ProtoReflect.Descriptor instead.
+func (*CompareAndSwapRequest) Descriptor() ([]byte, []int) {
+	return file_raftapi_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *CompareAndSwapRequest) GetKey() string {
+	if x != nil && x.Key != nil {
+		return *x.Key
+	}
+	return ""
+}
+
+func (x *CompareAndSwapRequest) GetField() string {
+	if x != nil && x.Field != nil {
+		return *x.Field
+	}
+	return ""
+}
+
+func (x *CompareAndSwapRequest) GetExpected() []byte {
+	if x != nil {
+		return x.Expected
+	}
+	return nil
+}
+
+func (x *CompareAndSwapRequest) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+type CompareAndSwapResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Swapped       *bool                  `protobuf:"varint,1,opt,name=swapped" json:"swapped,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompareAndSwapResponse) Reset() {
+	*x = CompareAndSwapResponse{}
+	mi := &file_raftapi_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompareAndSwapResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompareAndSwapResponse) ProtoMessage() {}
+
+func (x *CompareAndSwapResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_raftapi_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompareAndSwapResponse.ProtoReflect.Descriptor instead.
+func (*CompareAndSwapResponse) Descriptor() ([]byte, []int) {
+	return file_raftapi_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *CompareAndSwapResponse) GetSwapped() bool {
+	if x != nil && x.Swapped != nil {
+		return *x.Swapped
+	}
+	return false
+}
+
 type CacheHitsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Cachehits     *uint64                `protobuf:"varint,1,opt,name=cachehits" json:"cachehits,omitempty"`
@@ -230,7 +938,7 @@ type CacheHitsResponse struct {
 
 func (x *CacheHitsResponse) Reset() {
 	*x = CacheHitsResponse{}
-	mi := &file_raftapi_proto_msgTypes[4]
+	mi := &file_raftapi_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -242,7 +950,7 @@ func (x *CacheHitsResponse) String() string {
 func (*CacheHitsResponse) ProtoMessage() {}
 
 func (x *CacheHitsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_raftapi_proto_msgTypes[4]
+	mi := &file_raftapi_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -255,7 +963,7 @@ func (x *CacheHitsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CacheHitsResponse.ProtoReflect.Descriptor instead.
 func (*CacheHitsResponse) Descriptor() ([]byte, []int) {
-	return file_raftapi_proto_rawDescGZIP(), []int{4}
+	return file_raftapi_proto_rawDescGZIP(), []int{16}
 }
 
 func (x *CacheHitsResponse) GetCachehits() uint64 {
@@ -274,7 +982,7 @@ type RestoredResponse struct {
 
 func (x *RestoredResponse) Reset() {
 	*x = RestoredResponse{}
-	mi := &file_raftapi_proto_msgTypes[5]
+	mi := &file_raftapi_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -286,7 +994,7 @@ func (x *RestoredResponse) String() string {
 func (*RestoredResponse) ProtoMessage() {}
 
 func (x *RestoredResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_raftapi_proto_msgTypes[5]
+	mi := &file_raftapi_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -299,7 +1007,7 @@ func (x *RestoredResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RestoredResponse.ProtoReflect.Descriptor instead.
 func (*RestoredResponse) Descriptor() ([]byte, []int) {
-	return file_raftapi_proto_rawDescGZIP(), []int{5}
+	return file_raftapi_proto_rawDescGZIP(), []int{17}
 }
 
 func (x *RestoredResponse) GetRestored() uint64 {
@@ -317,7 +1025,7 @@ type Empty struct {
 
 func (x *Empty) Reset() {
 	*x = Empty{}
-	mi := &file_raftapi_proto_msgTypes[6]
+	mi := &file_raftapi_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -329,7 +1037,7 @@ func (x *Empty) String() string {
 func (*Empty) ProtoMessage() {}
 
 func (x *Empty) ProtoReflect() protoreflect.Message {
-	mi := &file_raftapi_proto_msgTypes[6]
+	mi := &file_raftapi_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -342,35 +1050,84 @@ func (x *Empty) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Empty.ProtoReflect.Descriptor instead.
 func (*Empty) Descriptor() ([]byte, []int) {
-	return file_raftapi_proto_rawDescGZIP(), []int{6}
+	return file_raftapi_proto_rawDescGZIP(), []int{18}
 }
 
 var File_raftapi_proto protoreflect.FileDescriptor
 
 const file_raftapi_proto_rawDesc = "" +
 	"\n" +
-	"\rraftapi.proto\x12\araftapi\"4\n" +
+	"\rraftapi.proto\x12\araftapi\"K\n" +
 	"\n" +
 	"PutRequest\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value\"5\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\x12\x15\n" +
+	"\x06ttl_ms\x18\x03 \x01(\x04R\x05ttlMs\"5\n" +
 	"\vPutResponse\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value\"\x1e\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\"Z\n" +
 	"\n" +
 	"GetRequest\x12\x10\n" +
-	"\x03key\x18\x01 \x01(\tR\x03key\"9\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12:\n" +
+	"\vconsistency\x18\x02 \x01(\x0e2\x18.raftapi.ReadConsistencyR\vconsistency\"9\n" +
 	"\vGetResponse\x12\x14\n" +
 	"\x05found\x18\x01 \x01(\bR\x05found\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value\"1\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\"!\n" +
+	"\rDeleteRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\"u\n" +
+	"\vScanRequest\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\tR\x05start\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\rR\x05limit\x12:\n" +
+	"\vconsistency\x18\x03 \x01(\x0e2\x18.raftapi.ReadConsistencyR\vconsistency\"2\n" +
+	"\bKeyValue\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\"3\n" +
+	"\fScanResponse\x12#\n" +
+	"\x03kvs\x18\x01 \x03(\v2\x11.raftapi.KeyValueR\x03kvs\":\n" +
+	"\x0fBatchPutRequest\x12'\n" +
+	"\x04puts\x18\x01 \x03(\v2\x13.raftapi.PutRequestR\x04puts\"a\n" +
+	"\x0fBatchGetRequest\x12\x12\n" +
+	"\x04keys\x18\x01 \x03(\tR\x04keys\x12:\n" +
+	"\vconsistency\x18\x02 \x01(\x0e2\x18.raftapi.ReadConsistencyR\vconsistency\"@\n" +
+	"\x10BatchGetResponse\x12,\n" +
+	"\x06values\x18\x01 \x03(\v2\x14.raftapi.GetResponseR\x06values\"P\n" +
+	"\x10IncrementRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05field\x18\x02 \x01(\tR\x05field\x12\x14\n" +
+	"\x05delta\x18\x03 \x01(\x03R\x05delta\")\n" +
+	"\x11IncrementResponse\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\x03R\x05value\"g\n" +
+	"\rAppendRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05field\x18\x02 \x01(\tR\x05field\x12\x14\n" +
+	"\x05value\x18\x03 \x01(\fR\x05value\x12\x18\n" +
+	"\aprepend\x18\x04 \x01(\bR\aprepend\"q\n" +
+	"\x15CompareAndSwapRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05field\x18\x02 \x01(\tR\x05field\x12\x1a\n" +
+	"\bexpected\x18\x03 \x01(\fR\bexpected\x12\x14\n" +
+	"\x05value\x18\x04 \x01(\fR\x05value\"2\n" +
+	"\x16CompareAndSwapResponse\x12\x18\n" +
+	"\aswapped\x18\x01 \x01(\bR\aswapped\"1\n" +
 	"\x11CacheHitsResponse\x12\x1c\n" +
 	"\tcachehits\x18\x01 \x01(\x04R\tcachehits\".\n" +
 	"\x10RestoredResponse\x12\x1a\n" +
 	"\brestored\x18\x01 \x01(\x04R\brestored\"\a\n" +
-	"\x05Empty2\xcc\x02\n" +
+	"\x05Empty*9\n" +
+	"\x0fReadConsistency\x12\x10\n" +
+	"\fLINEARIZABLE\x10\x00\x12\t\n" +
+	"\x05LEASE\x10\x01\x12\t\n" +
+	"\x05STALE\x10\x022\xf3\x05\n" +
 	"\rRaftKVService\x120\n" +
 	"\x03Put\x12\x13.raftapi.PutRequest\x1a\x14.raftapi.PutResponse\x120\n" +
-	"\x03Get\x12\x13.raftapi.GetRequest\x1a\x14.raftapi.GetResponse\x12:\n" +
+	"\x03Get\x12\x13.raftapi.GetRequest\x1a\x14.raftapi.GetResponse\x120\n" +
+	"\x06Delete\x12\x16.raftapi.DeleteRequest\x1a\x0e.raftapi.Empty\x123\n" +
+	"\x04Scan\x12\x14.raftapi.ScanRequest\x1a\x15.raftapi.ScanResponse\x124\n" +
+	"\bBatchPut\x12\x18.raftapi.BatchPutRequest\x1a\x0e.raftapi.Empty\x12?\n" +
+	"\bBatchGet\x12\x18.raftapi.BatchGetRequest\x1a\x19.raftapi.BatchGetResponse\x12B\n" +
+	"\tIncrement\x12\x19.raftapi.IncrementRequest\x1a\x1a.raftapi.IncrementResponse\x120\n" +
+	"\x06Append\x12\x16.raftapi.AppendRequest\x1a\x0e.raftapi.Empty\x12Q\n" +
+	"\x0eCompareAndSwap\x12\x1e.raftapi.CompareAndSwapRequest\x1a\x1f.raftapi.CompareAndSwapResponse\x12:\n" +
 	"\fGetCacheHits\x12\x0e.raftapi.Empty\x1a\x1a.raftapi.CacheHitsResponse\x120\n" +
 	"\x0eResetCacheHits\x12\x0e.raftapi.Empty\x1a\x0e.raftapi.Empty\x128\n" +
 	"\vGetRestored\x12\x0e.raftapi.Empty\x1a\x19.raftapi.RestoredResponse\x12/\n" +
@@ -389,34 +1146,68 @@ func file_raftapi_proto_rawDescGZIP() []byte {
 	return file_raftapi_proto_rawDescData
 }
 
-var file_raftapi_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_raftapi_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_raftapi_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
 var file_raftapi_proto_goTypes = []any{
-	(*PutRequest)(nil),        // 0: raftapi.PutRequest
-	(*PutResponse)(nil),       // 1: raftapi.PutResponse
-	(*GetRequest)(nil),        // 2: raftapi.GetRequest
-	(*GetResponse)(nil),       // 3: raftapi.GetResponse
-	(*CacheHitsResponse)(nil), // 4: raftapi.CacheHitsResponse
-	(*RestoredResponse)(nil),  // 5: raftapi.RestoredResponse
-	(*Empty)(nil),             // 6: raftapi.Empty
+	(ReadConsistency)(0),           // 0: raftapi.ReadConsistency
+	(*PutRequest)(nil),             // 1: raftapi.PutRequest
+	(*PutResponse)(nil),            // 2: raftapi.PutResponse
+	(*GetRequest)(nil),             // 3: raftapi.GetRequest
+	(*GetResponse)(nil),            // 4: raftapi.GetResponse
+	(*DeleteRequest)(nil),          // 5: raftapi.DeleteRequest
+	(*ScanRequest)(nil),            // 6: raftapi.ScanRequest
+	(*KeyValue)(nil),               // 7: raftapi.KeyValue
+	(*ScanResponse)(nil),           // 8: raftapi.ScanResponse
+	(*BatchPutRequest)(nil),        // 9: raftapi.BatchPutRequest
+	(*BatchGetRequest)(nil),        // 10: raftapi.BatchGetRequest
+	(*BatchGetResponse)(nil),       // 11: raftapi.BatchGetResponse
+	(*IncrementRequest)(nil),       // 12: raftapi.IncrementRequest
+	(*IncrementResponse)(nil),      // 13: raftapi.IncrementResponse
+	(*AppendRequest)(nil),          // 14: raftapi.AppendRequest
+	(*CompareAndSwapRequest)(nil),  // 15: raftapi.CompareAndSwapRequest
+	(*CompareAndSwapResponse)(nil), // 16: raftapi.CompareAndSwapResponse
+	(*CacheHitsResponse)(nil),      // 17: raftapi.CacheHitsResponse
+	(*RestoredResponse)(nil),       // 18: raftapi.RestoredResponse
+	(*Empty)(nil),                  // 19: raftapi.Empty
 }
 var file_raftapi_proto_depIdxs = []int32{
-	0, // 0: raftapi.RaftKVService.Put:input_type -> raftapi.PutRequest
-	2, // 1: raftapi.RaftKVService.Get:input_type -> raftapi.GetRequest
-	6, // 2: raftapi.RaftKVService.GetCacheHits:input_type -> raftapi.Empty
-	6, // 3: raftapi.RaftKVService.ResetCacheHits:input_type -> raftapi.Empty
-	6, // 4: raftapi.RaftKVService.GetRestored:input_type -> raftapi.Empty
-	6, // 5: raftapi.RaftKVService.ResetRestored:input_type -> raftapi.Empty
-	1, // 6: raftapi.RaftKVService.Put:output_type -> raftapi.PutResponse
-	3, // 7: raftapi.RaftKVService.Get:output_type -> raftapi.GetResponse
-	4, // 8: raftapi.RaftKVService.GetCacheHits:output_type -> raftapi.CacheHitsResponse
-	6, // 9: raftapi.RaftKVService.ResetCacheHits:output_type -> raftapi.Empty
-	5, // 10: raftapi.RaftKVService.GetRestored:output_type -> raftapi.RestoredResponse
-	6, // 11: raftapi.RaftKVService.ResetRestored:output_type -> raftapi.Empty
-	6, // [6:12] is the sub-list for method output_type
-	0, // [0:6] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	0,  // 0: raftapi.GetRequest.consistency:type_name -> raftapi.ReadConsistency
+	0,  // 1: raftapi.ScanRequest.consistency:type_name -> raftapi.ReadConsistency
+	7,  // 2: raftapi.ScanResponse.kvs:type_name -> raftapi.KeyValue
+	1,  // 3: raftapi.BatchPutRequest.puts:type_name -> raftapi.PutRequest
+	0,  // 4: raftapi.BatchGetRequest.consistency:type_name -> raftapi.ReadConsistency
+	4,  // 5: raftapi.BatchGetResponse.values:type_name -> raftapi.GetResponse
+	1,  // 6: raftapi.RaftKVService.Put:input_type -> raftapi.PutRequest
+	3,  // 7: raftapi.RaftKVService.Get:input_type -> raftapi.GetRequest
+	5,  // 8: raftapi.RaftKVService.Delete:input_type -> raftapi.DeleteRequest
+	6,  // 9: raftapi.RaftKVService.Scan:input_type -> raftapi.ScanRequest
+	9,  // 10: raftapi.RaftKVService.BatchPut:input_type -> raftapi.BatchPutRequest
+	10, // 11: raftapi.RaftKVService.BatchGet:input_type -> raftapi.BatchGetRequest
+	12, // 12: raftapi.RaftKVService.Increment:input_type -> raftapi.IncrementRequest
+	14, // 13: raftapi.RaftKVService.Append:input_type -> raftapi.AppendRequest
+	15, // 14: raftapi.RaftKVService.CompareAndSwap:input_type -> raftapi.CompareAndSwapRequest
+	19, // 15: raftapi.RaftKVService.GetCacheHits:input_type -> raftapi.Empty
+	19, // 16: raftapi.RaftKVService.ResetCacheHits:input_type -> raftapi.Empty
+	19, // 17: raftapi.RaftKVService.GetRestored:input_type -> raftapi.Empty
+	19, // 18: raftapi.RaftKVService.ResetRestored:input_type -> raftapi.Empty
+	2,  // 19: raftapi.RaftKVService.Put:output_type -> raftapi.PutResponse
+	4,  // 20: raftapi.RaftKVService.Get:output_type -> raftapi.GetResponse
+	19, // 21: raftapi.RaftKVService.Delete:output_type -> raftapi.Empty
+	8,  // 22: raftapi.RaftKVService.Scan:output_type -> raftapi.ScanResponse
+	19, // 23: raftapi.RaftKVService.BatchPut:output_type -> raftapi.Empty
+	11, // 24: raftapi.RaftKVService.BatchGet:output_type -> raftapi.BatchGetResponse
+	13, // 25: raftapi.RaftKVService.Increment:output_type -> raftapi.IncrementResponse
+	19, // 26: raftapi.RaftKVService.Append:output_type -> raftapi.Empty
+	16, // 27: raftapi.RaftKVService.CompareAndSwap:output_type -> raftapi.CompareAndSwapResponse
+	17, // 28: raftapi.RaftKVService.GetCacheHits:output_type -> raftapi.CacheHitsResponse
+	19, // 29: raftapi.RaftKVService.ResetCacheHits:output_type -> raftapi.Empty
+	18, // 30: raftapi.RaftKVService.GetRestored:output_type -> raftapi.RestoredResponse
+	19, // 31: raftapi.RaftKVService.ResetRestored:output_type -> raftapi.Empty
+	19, // [19:32] is the sub-list for method output_type
+	6,  // [6:19] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_raftapi_proto_init() }
@@ -429,13 +1220,14 @@ func file_raftapi_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_raftapi_proto_rawDesc), len(file_raftapi_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   7,
+			NumEnums:      1,
+			NumMessages:   19,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_raftapi_proto_goTypes,
 		DependencyIndexes: file_raftapi_proto_depIdxs,
+		EnumInfos:         file_raftapi_proto_enumTypes,
 		MessageInfos:      file_raftapi_proto_msgTypes,
 	}.Build()
 	File_raftapi_proto = out.File
@@ -21,6 +21,13 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	RaftKVService_Put_FullMethodName            = "/raftapi.RaftKVService/Put"
 	RaftKVService_Get_FullMethodName            = "/raftapi.RaftKVService/Get"
+	RaftKVService_Delete_FullMethodName         = "/raftapi.RaftKVService/Delete"
+	RaftKVService_Scan_FullMethodName           = "/raftapi.RaftKVService/Scan"
+	RaftKVService_BatchPut_FullMethodName       = "/raftapi.RaftKVService/BatchPut"
+	RaftKVService_BatchGet_FullMethodName       = "/raftapi.RaftKVService/BatchGet"
+	RaftKVService_Increment_FullMethodName      = "/raftapi.RaftKVService/Increment"
+	RaftKVService_Append_FullMethodName         = "/raftapi.RaftKVService/Append"
+	RaftKVService_CompareAndSwap_FullMethodName = "/raftapi.RaftKVService/CompareAndSwap"
 	RaftKVService_GetCacheHits_FullMethodName   = "/raftapi.RaftKVService/GetCacheHits"
 	RaftKVService_ResetCacheHits_FullMethodName = "/raftapi.RaftKVService/ResetCacheHits"
 	RaftKVService_GetRestored_FullMethodName    = "/raftapi.RaftKVService/GetRestored"
@@ -34,6 +41,19 @@ type RaftKVServiceClient interface {
 	// Existing unary methods:
 	Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error)
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*Empty, error)
+	// Returns up to limit keys, in key order, from the first key >= start.
+	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (*ScanResponse, error)
+	// Applies all puts in one proposal.
+	BatchPut(ctx context.Context, in *BatchPutRequest, opts ...grpc.CallOption) (*Empty, error)
+	// Returns one response per key, in the order of the keys.
+	BatchGet(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*BatchGetResponse, error)
+	// Read-modify-write of one field of a JSON-encoded row, applied atomically
+	// by the leader. Missing rows and fields start out empty.
+	Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*IncrementResponse, error)
+	Append(ctx context.Context, in *AppendRequest, opts ...grpc.CallOption) (*Empty, error)
+	// Writes the field only if it currently holds the expected value.
+	CompareAndSwap(ctx context.Context, in *CompareAndSwapRequest, opts ...grpc.CallOption) (*CompareAndSwapResponse, error)
 	GetCacheHits(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CacheHitsResponse, error)
 	ResetCacheHits(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
 	GetRestored(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*RestoredResponse, error)
@@ -68,6 +88,76 @@ func (c *raftKVServiceClient) Get(ctx context.Context, in *GetRequest, opts ...g
 	return out, nil
 }
 
+func (c *raftKVServiceClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, RaftKVService_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftKVServiceClient) Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (*ScanResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScanResponse)
+	err := c.cc.Invoke(ctx, RaftKVService_Scan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftKVServiceClient) BatchPut(ctx context.Context, in *BatchPutRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, RaftKVService_BatchPut_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftKVServiceClient) BatchGet(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*BatchGetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchGetResponse)
+	err := c.cc.Invoke(ctx, RaftKVService_BatchGet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftKVServiceClient) Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*IncrementResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IncrementResponse)
+	err := c.cc.Invoke(ctx, RaftKVService_Increment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftKVServiceClient) Append(ctx context.Context, in *AppendRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, RaftKVService_Append_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftKVServiceClient) CompareAndSwap(ctx context.Context, in *CompareAndSwapRequest, opts ...grpc.CallOption) (*CompareAndSwapResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CompareAndSwapResponse)
+	err := c.cc.Invoke(ctx, RaftKVService_CompareAndSwap_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *raftKVServiceClient) GetCacheHits(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CacheHitsResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(CacheHitsResponse)
@@ -115,6 +205,19 @@ type RaftKVServiceServer interface {
 	// Existing unary methods:
 	Put(context.Context, *PutRequest) (*PutResponse, error)
 	Get(context.Context, *GetRequest) (*GetResponse, error)
+	Delete(context.Context, *DeleteRequest) (*Empty, error)
+	// Returns up to limit keys, in key order, from the first key >= start.
+	Scan(context.Context, *ScanRequest) (*ScanResponse, error)
+	// Applies all puts in one proposal.
+	BatchPut(context.Context, *BatchPutRequest) (*Empty, error)
+	// Returns one response per key, in the order of the keys.
+	BatchGet(context.Context, *BatchGetRequest) (*BatchGetResponse, error)
+	// Read-modify-write of one field of a JSON-encoded row, applied atomically
+	// by the leader. Missing rows and fields start out empty.
+	Increment(context.Context, *IncrementRequest) (*IncrementResponse, error)
+	Append(context.Context, *AppendRequest) (*Empty, error)
+	// Writes the field only if it currently holds the expected value.
+	CompareAndSwap(context.Context, *CompareAndSwapRequest) (*CompareAndSwapResponse, error)
 	GetCacheHits(context.Context, *Empty) (*CacheHitsResponse, error)
 	ResetCacheHits(context.Context, *Empty) (*Empty, error)
 	GetRestored(context.Context, *Empty) (*RestoredResponse, error)
@@ -135,6 +238,27 @@ func (UnimplementedRaftKVServiceServer) Put(context.Context, *PutRequest) (*PutR
 func (UnimplementedRaftKVServiceServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method Get not implemented")
 }
+func (UnimplementedRaftKVServiceServer) Delete(context.Context, *DeleteRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedRaftKVServiceServer) Scan(context.Context, *ScanRequest) (*ScanResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Scan not implemented")
+}
+func (UnimplementedRaftKVServiceServer) BatchPut(context.Context, *BatchPutRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method BatchPut not implemented")
+}
+func (UnimplementedRaftKVServiceServer) BatchGet(context.Context, *BatchGetRequest) (*BatchGetResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method BatchGet not implemented")
+}
+func (UnimplementedRaftKVServiceServer) Increment(context.Context, *IncrementRequest) (*IncrementResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Increment not implemented")
+}
+func (UnimplementedRaftKVServiceServer) Append(context.Context, *AppendRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method Append not implemented")
+}
+func (UnimplementedRaftKVServiceServer) CompareAndSwap(context.Context, *CompareAndSwapRequest) (*CompareAndSwapResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CompareAndSwap not implemented")
+}
 func (UnimplementedRaftKVServiceServer) GetCacheHits(context.Context, *Empty) (*CacheHitsResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method GetCacheHits not implemented")
 }
@@ -204,6 +328,132 @@ func _RaftKVService_Get_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _RaftKVService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftKVServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RaftKVService_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftKVServiceServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RaftKVService_Scan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftKVServiceServer).Scan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RaftKVService_Scan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftKVServiceServer).Scan(ctx, req.(*ScanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RaftKVService_BatchPut_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchPutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftKVServiceServer).BatchPut(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RaftKVService_BatchPut_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftKVServiceServer).BatchPut(ctx, req.(*BatchPutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RaftKVService_BatchGet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftKVServiceServer).BatchGet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RaftKVService_BatchGet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftKVServiceServer).BatchGet(ctx, req.(*BatchGetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RaftKVService_Increment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IncrementRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftKVServiceServer).Increment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RaftKVService_Increment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftKVServiceServer).Increment(ctx, req.(*IncrementRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RaftKVService_Append_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftKVServiceServer).Append(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RaftKVService_Append_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftKVServiceServer).Append(ctx, req.(*AppendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RaftKVService_CompareAndSwap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompareAndSwapRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftKVServiceServer).CompareAndSwap(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RaftKVService_CompareAndSwap_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftKVServiceServer).CompareAndSwap(ctx, req.(*CompareAndSwapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _RaftKVService_GetCacheHits_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Empty)
 	if err := dec(in); err != nil {
@@ -291,6 +541,34 @@ var RaftKVService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Get",
 			Handler:    _RaftKVService_Get_Handler,
 		},
+		{
+			MethodName: "Delete",
+			Handler:    _RaftKVService_Delete_Handler,
+		},
+		{
+			MethodName: "Scan",
+			Handler:    _RaftKVService_Scan_Handler,
+		},
+		{
+			MethodName: "BatchPut",
+			Handler:    _RaftKVService_BatchPut_Handler,
+		},
+		{
+			MethodName: "BatchGet",
+			Handler:    _RaftKVService_BatchGet_Handler,
+		},
+		{
+			MethodName: "Increment",
+			Handler:    _RaftKVService_Increment_Handler,
+		},
+		{
+			MethodName: "Append",
+			Handler:    _RaftKVService_Append_Handler,
+		},
+		{
+			MethodName: "CompareAndSwap",
+			Handler:    _RaftKVService_CompareAndSwap_Handler,
+		},
 		{
 			MethodName: "GetCacheHits",
 			Handler:    _RaftKVService_GetCacheHits_Handler,
@@ -0,0 +1,340 @@
+package raft
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/pingcap/go-ycsb/db/raft/raftapi"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+)
+
+// Routing policies of raft.routing and raft.read_routing
+const (
+	routeLeader     = "leader"
+	routeRoundRobin = "round_robin"
+	routeNearest    = "nearest"
+	routeRandom     = "random"
+)
+
+// failoverOp is the operation the time to recover from failed or redirected
+// requests is measured as.
+const failoverOp = "FAILOVER"
+
+// leaderTrailer is the trailer a node may set to the address of the leader
+// when it rejects a request as not the leader.
+const leaderTrailer = "raft-leader"
+
+// maxNodes is the most nodes a router handles, one per bit of the nodes a
+// request was sent to.
+const maxNodes = 64
+
+// pingTimeout bounds the requests which check whether a node that was down is
+// reachable again, and minPingInterval is the shortest time between them.
+const (
+	pingTimeout     = time.Second
+	minPingInterval = 10 * time.Millisecond
+)
+
+// leaderHint matches the address of the leader in a not leader error message,
+// as in "not leader, leader=10.0.0.2:12380".
+var leaderHint = regexp.MustCompile(`leader[=:] ?([^\s,;)]+)`)
+
+// node is one raft server.
+type node struct {
+	addr   string
+	conn   *grpc.ClientConn
+	client raftapi.RaftKVServiceClient
+	// rtt is the lowest round trip time measured when connecting, MaxInt64 if
+	// the node was unreachable
+	rtt time.Duration
+	// down is set while the node is unreachable, until a ping reaches it
+	// again
+	down int32
+}
+
+// router picks the node of every request and retries requests on other
+// nodes.
+type router struct {
+	nodes   []*node
+	routing string
+	// readRouting routes the requests any node serves, such as stale reads
+	readRouting string
+	// leader is the index of the node believed to be the leader, -1 until a
+	// node redirects
+	leader int32
+	// next is the round-robin counter
+	next uint32
+	// nearest has the node indexes ordered by rtt
+	nearest []int
+
+	maxRetries int
+	backoff    time.Duration
+
+	// closed stops the pings of nodes which are down
+	closed chan struct{}
+}
+
+func newRouter(nodes []*node, routing string, readRouting string, maxRetries int, backoff time.Duration) (*router, error) {
+	for _, policy := range []string{routing, readRouting} {
+		switch policy {
+		case routeLeader, routeRoundRobin, routeNearest, routeRandom:
+		default:
+			return nil, fmt.Errorf("unknown raft routing %s", policy)
+		}
+	}
+	if len(nodes) > maxNodes {
+		return nil, fmt.Errorf("too many raft nodes %d, at most %d are supported", len(nodes), maxNodes)
+	}
+
+	r := &router{
+		nodes:       nodes,
+		routing:     routing,
+		readRouting: readRouting,
+		leader:      -1,
+		maxRetries:  maxRetries,
+		backoff:     backoff,
+		closed:      make(chan struct{}),
+	}
+	r.nearest = make([]int, len(nodes))
+	for i := range r.nearest {
+		r.nearest[i] = i
+	}
+	sort.SliceStable(r.nearest, func(i, j int) bool {
+		return nodes[r.nearest[i]].rtt < nodes[r.nearest[j]].rtt
+	})
+	return r, nil
+}
+
+// close stops the pings of the nodes which are down.
+func (r *router) close() {
+	close(r.closed)
+}
+
+// ping sends n a request which any node answers.
+func (n *node) ping(ctx context.Context, opts ...grpc.CallOption) error {
+	_, err := n.client.GetCacheHits(ctx, &raftapi.Empty{}, opts...)
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	return err
+}
+
+// probe measures the round trip time to n, trying for up to timeout.
+func (n *node) probe(timeout time.Duration) {
+	n.rtt = math.MaxInt64
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		if err := n.ping(ctx, grpc.WaitForReady(true)); err != nil {
+			return
+		}
+		if rtt := time.Since(start); rtt < n.rtt {
+			n.rtt = rtt
+		}
+	}
+}
+
+// markDown marks node i as down, so that requests are routed to other nodes,
+// and pings it every backoff until it is reachable again. It returns false if
+// the node was already down.
+func (r *router) markDown(i int) bool {
+	n := r.nodes[i]
+	if !atomic.CompareAndSwapInt32(&n.down, 0, 1) {
+		return false
+	}
+	// A leader which is down no longer leads
+	atomic.CompareAndSwapInt32(&r.leader, int32(i), -1)
+	interval := r.backoff
+	if interval < minPingInterval {
+		interval = minPingInterval
+	}
+	go func() {
+		for {
+			select {
+			case <-r.closed:
+				return
+			case <-time.After(interval):
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+			err := n.ping(ctx)
+			cancel()
+			if err == nil {
+				atomic.StoreInt32(&n.down, 0)
+				return
+			}
+		}
+	}()
+	return true
+}
+
+func (r *router) isDown(i int) bool {
+	return atomic.LoadInt32(&r.nodes[i].down) != 0
+}
+
+// pick returns the node to send a request routed with routing to first.
+// Nodes which are down are skipped, unless every node is down.
+func (r *router) pick(routing string) int {
+	switch routing {
+	case routeRoundRobin:
+		return r.up(int(atomic.AddUint32(&r.next, 1) % uint32(len(r.nodes))))
+	case routeNearest:
+		return r.nearestUp()
+	case routeRandom:
+		return r.up(rand.Intn(len(r.nodes)))
+	default:
+		if l := atomic.LoadInt32(&r.leader); l >= 0 {
+			return int(l)
+		}
+		// Until a node redirects, the nearest node is assumed to lead
+		return r.nearestUp()
+	}
+}
+
+// up returns the first node from i on which is not down, or i if every node
+// is down.
+func (r *router) up(i int) int {
+	for k := 0; k < len(r.nodes); k++ {
+		if j := (i + k) % len(r.nodes); !r.isDown(j) {
+			return j
+		}
+	}
+	return i
+}
+
+// nearestUp returns the nearest node which is not down, or the nearest node if
+// every node is down.
+func (r *router) nearestUp() int {
+	for _, i := range r.nearest {
+		if !r.isDown(i) {
+			return i
+		}
+	}
+	return r.nearest[0]
+}
+
+// retryable reports whether a request failed because the node can't be
+// reached, or because it is not the leader. In that case it also returns the
+// index of the leader if the node named it, or -1.
+func (r *router) retryable(err error, trailer metadata.MD) (unavailable bool, notLeader bool, leader int) {
+	s, ok := status.FromError(err)
+	if !ok {
+		return false, false, -1
+	}
+	msg := strings.ToLower(s.Message())
+	switch {
+	case strings.Contains(msg, "not leader"), strings.Contains(msg, "not the leader"), s.Code() == codes.FailedPrecondition:
+	case s.Code() == codes.Unavailable:
+		return true, false, -1
+	default:
+		return false, false, -1
+	}
+
+	var hint string
+	if v := trailer.Get(leaderTrailer); len(v) > 0 {
+		hint = v[0]
+	} else if m := leaderHint.FindStringSubmatch(s.Message()); m != nil {
+		hint = m[1]
+	}
+	if hint != "" {
+		for i, n := range r.nodes {
+			if n.addr == hint {
+				return false, true, i
+			}
+		}
+	}
+	return false, true, -1
+}
+
+// call sends an idempotent request with f, to the node picked by the routing
+// policy, and retries it on the leader or on the next node while nodes report
+// they are not the leader or are unavailable.
+func (r *router) call(ctx context.Context, f func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error) error {
+	return r.send(ctx, r.routing, true, f)
+}
+
+// callAny is like call for a request which any node serves without
+// redirecting it to the leader, such as a stale read. It is routed with
+// raft.read_routing instead of raft.routing.
+func (r *router) callAny(ctx context.Context, f func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error) error {
+	return r.send(ctx, r.readRouting, true, f)
+}
+
+// callOnce is like call for a request which must not be applied twice, such
+// as an increment. A node which is unavailable may have applied it, so it is
+// only retried when a node refused it as not the leader.
+func (r *router) callOnce(ctx context.Context, f func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error) error {
+	return r.send(ctx, r.routing, false, f)
+}
+
+// send sends a request with f, to the node picked by routing, and retries it. Before a node is asked a second
+// time, such as after every node failed or when two nodes name each other as
+// the leader, the retry waits for the backoff. If the request failed on the
+// known leader, or on a node which wasn't known to be down, the time from the
+// failure to the success of a retry is measured as FAILOVER. Redirects from
+// followers and nodes skipped as down are not failovers.
+func (r *router) send(ctx context.Context, routing string, retryUnavailable bool, f func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error) error {
+	i := r.pick(routing)
+	var failed time.Time
+	var redirected bool
+	// asked has a bit for every node asked since the last backoff
+	var asked uint64
+	for attempt := 0; ; attempt++ {
+		if asked&(1<<uint(i)) != 0 {
+			// Wait for an election
+			select {
+			case <-ctx.Done():
+			case <-time.After(r.backoff):
+			}
+			asked = 0
+		}
+		asked |= 1 << uint(i)
+
+		var trailer metadata.MD
+		err := f(r.nodes[i].client, grpc.Trailer(&trailer))
+		if err == nil {
+			if redirected {
+				// The node which took the request after others refused it leads
+				atomic.StoreInt32(&r.leader, int32(i))
+			}
+			if !failed.IsZero() {
+				measurement.MeasureContext(ctx, failoverOp, "", failed, time.Since(failed))
+			}
+			return nil
+		}
+
+		unavailable, notLeader, leader := r.retryable(err, trailer)
+		wasLeader := int32(i) == atomic.LoadInt32(&r.leader)
+		wasUp := unavailable && r.markDown(i)
+		if failed.IsZero() && (wasUp || (wasLeader && (unavailable || notLeader))) {
+			failed = time.Now()
+		}
+		redirected = redirected || notLeader
+		retry := notLeader || (unavailable && retryUnavailable)
+		if !retry || attempt >= r.maxRetries || ctx.Err() != nil {
+			if !failed.IsZero() {
+				measurement.MeasureContext(ctx, failoverOp+"_ERROR", "", failed, time.Since(failed))
+			}
+			return err
+		}
+
+		if leader >= 0 && leader != i {
+			atomic.StoreInt32(&r.leader, int32(leader))
+			i = leader
+			continue
+		}
+		i = r.up((i + 1) % len(r.nodes))
+	}
+}
@@ -0,0 +1,235 @@
+package raft
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/pingcap/go-ycsb/db/raft/raftapi"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+)
+
+var errUnavailable = status.Error(codes.Unavailable, "connection refused")
+
+// fakeNode is a raft node which fails every request with err if it is set,
+// naming leader in the raft-leader trailer if it is set too.
+type fakeNode struct {
+	raftapi.RaftKVServiceClient
+
+	mu     sync.Mutex
+	err    error
+	leader string
+	calls  int
+}
+
+func (n *fakeNode) set(err error, leader string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.err, n.leader = err, leader
+}
+
+func (n *fakeNode) callCount() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls
+}
+
+func (n *fakeNode) reply(opts []grpc.CallOption) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls++
+	if n.leader != "" {
+		for _, o := range opts {
+			if t, ok := o.(grpc.TrailerCallOption); ok {
+				*t.TrailerAddr = metadata.Pairs(leaderTrailer, n.leader)
+			}
+		}
+	}
+	return n.err
+}
+
+func (n *fakeNode) Put(ctx context.Context, in *raftapi.PutRequest, opts ...grpc.CallOption) (*raftapi.PutResponse, error) {
+	return &raftapi.PutResponse{}, n.reply(opts)
+}
+
+// Get finds every key, with an empty JSON row.
+func (n *fakeNode) Get(ctx context.Context, in *raftapi.GetRequest, opts ...grpc.CallOption) (*raftapi.GetResponse, error) {
+	found, value := true, "{}"
+	return &raftapi.GetResponse{Found: &found, Value: &value}, n.reply(opts)
+}
+
+func (n *fakeNode) Increment(ctx context.Context, in *raftapi.IncrementRequest, opts ...grpc.CallOption) (*raftapi.IncrementResponse, error) {
+	return &raftapi.IncrementResponse{}, n.reply(opts)
+}
+
+// GetCacheHits answers pings without counting them as calls.
+func (n *fakeNode) GetCacheHits(ctx context.Context, in *raftapi.Empty, opts ...grpc.CallOption) (*raftapi.CacheHitsResponse, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return &raftapi.CacheHitsResponse{}, n.err
+}
+
+// newFakeRouter returns a router over fakes named n0, n1 ..., nearest first.
+func newFakeRouter(t *testing.T, routing string, fakes ...*fakeNode) *router {
+	nodes := make([]*node, len(fakes))
+	for i, f := range fakes {
+		nodes[i] = &node{addr: fmt.Sprintf("n%d", i), client: f, rtt: time.Duration(i)}
+	}
+	r, err := newRouter(nodes, routing, routeNearest, 5, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.close)
+	measurement.InitMeasure(properties.NewProperties())
+	return r
+}
+
+func put(r *router) error {
+	return r.call(context.Background(), func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error {
+		_, err := c.Put(context.Background(), &raftapi.PutRequest{}, opts...)
+		return err
+	})
+}
+
+func increment(r *router) error {
+	return r.callOnce(context.Background(), func(c raftapi.RaftKVServiceClient, opts ...grpc.CallOption) error {
+		_, err := c.Increment(context.Background(), &raftapi.IncrementRequest{}, opts...)
+		return err
+	})
+}
+
+// failovers returns the number of failovers measured since the router was
+// created or failovers was last called.
+func failovers() int64 {
+	measurement.OutputPhase("failover")
+	phases := measurement.Phases()
+	if r := phases[len(phases)-1].Operations[failoverOp]; r != nil {
+		return r.Count
+	}
+	return 0
+}
+
+func TestRouterRedirect(t *testing.T) {
+	nodes := []*fakeNode{{}, {}, {}}
+	nodes[0].set(status.Error(codes.FailedPrecondition, "not leader, leader=n2"), "")
+	nodes[1].set(status.Error(codes.FailedPrecondition, "not leader"), "n0")
+	r := newFakeRouter(t, routeLeader, nodes...)
+
+	// The nearest node names the leader in the message
+	for i := 0; i < 2; i++ {
+		if err := put(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if nodes[0].callCount() != 1 || nodes[2].callCount() != 2 || r.leader != 2 {
+		t.Fatalf("want one redirect to n2, got calls %d %d %d and leader %d",
+			nodes[0].callCount(), nodes[1].callCount(), nodes[2].callCount(), r.leader)
+	}
+
+	// A new leader is named in the trailer
+	nodes[2].set(status.Error(codes.FailedPrecondition, "not leader"), "n1")
+	nodes[1].set(nil, "")
+	if err := put(r); err != nil {
+		t.Fatal(err)
+	}
+	if nodes[1].callCount() != 1 || r.leader != 1 {
+		t.Fatalf("want a redirect to n1, got %d calls and leader %d", nodes[1].callCount(), r.leader)
+	}
+	// Only the failure of the known leader is a failover
+	if n := failovers(); n != 1 {
+		t.Fatalf("want 1 failover, got %d", n)
+	}
+}
+
+func TestRouterUnavailable(t *testing.T) {
+	nodes := []*fakeNode{{}, {}, {}}
+	nodes[1].set(errUnavailable, "")
+	r := newFakeRouter(t, routeRoundRobin, nodes...)
+
+	for i := 0; i < 6; i++ {
+		if err := put(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// n1 is only asked until it is known to be down
+	if nodes[1].callCount() != 1 || !r.isDown(1) {
+		t.Fatalf("want n1 skipped after it failed, got %d calls", nodes[1].callCount())
+	}
+	if n := failovers(); n != 1 {
+		t.Fatalf("want 1 failover, got %d", n)
+	}
+
+	// n1 is routed to again once it answers a ping
+	nodes[1].set(nil, "")
+	deadline := time.Now().Add(5 * time.Second)
+	for r.isDown(1) {
+		if time.Now().After(deadline) {
+			t.Fatal("n1 still down after it recovered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		if err := put(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if nodes[1].callCount() != 2 {
+		t.Fatalf("want n1 asked again, got %d calls", nodes[1].callCount())
+	}
+}
+
+func TestRouterCallOnce(t *testing.T) {
+	nodes := []*fakeNode{{}, {}}
+	nodes[0].set(errUnavailable, "")
+	r := newFakeRouter(t, routeNearest, nodes...)
+
+	// The unavailable node may have applied the increment
+	if err := increment(r); status.Code(err) != codes.Unavailable {
+		t.Fatalf("want the unavailable error, got %v", err)
+	}
+	if nodes[1].callCount() != 0 {
+		t.Fatalf("want no retry, got %d calls of n1", nodes[1].callCount())
+	}
+	// The next request is routed past the node which is down
+	if err := increment(r); err != nil {
+		t.Fatal(err)
+	}
+
+	// A follower didn't apply it
+	nodes[1].set(status.Error(codes.FailedPrecondition, "not leader"), "n0")
+	nodes[0].set(nil, "")
+	calls := nodes[0].callCount()
+	if err := increment(r); err != nil {
+		t.Fatal(err)
+	}
+	if nodes[0].callCount() != calls+1 {
+		t.Fatalf("want the increment redirected to n0")
+	}
+}
+
+func TestRouterPingPong(t *testing.T) {
+	nodes := []*fakeNode{{}, {}}
+	nodes[0].set(status.Error(codes.FailedPrecondition, "not leader"), "n1")
+	nodes[1].set(status.Error(codes.FailedPrecondition, "not leader"), "n0")
+	r := newFakeRouter(t, routeLeader, nodes...)
+
+	start := time.Now()
+	if err := put(r); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("want the not leader error, got %v", err)
+	}
+	// 6 attempts, backing off before the 3rd and the 5th
+	if nodes[0].callCount() != 3 || nodes[1].callCount() != 3 {
+		t.Fatalf("want 3 calls of each node, got %d and %d", nodes[0].callCount(), nodes[1].callCount())
+	}
+	if elapsed := time.Since(start); elapsed < 2*r.backoff {
+		t.Fatalf("want the redirects to back off, took %s", elapsed)
+	}
+}
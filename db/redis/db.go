@@ -22,6 +22,19 @@ const JSON_SET string = "JSON.SET"
 const JSON_GET string = "JSON.GET"
 const HSET string = "HSET"
 const HMGET string = "HMGET"
+const PEXPIRE string = "PEXPIRE"
+const HINCRBY string = "HINCRBY"
+const EVAL string = "EVAL"
+
+// Redis has no in-place append for hash fields, so it is done by a script
+// which runs atomically on the server.
+const appendScript string = `local v = redis.call('HGET', KEYS[1], ARGV[1]) or ''
+return redis.call('HSET', KEYS[1], ARGV[1], v .. ARGV[2])`
+const prependScript string = `local v = redis.call('HGET', KEYS[1], ARGV[1]) or ''
+return redis.call('HSET', KEYS[1], ARGV[1], ARGV[2] .. v)`
+const casScript string = `if (redis.call('HGET', KEYS[1], ARGV[1]) or '') ~= ARGV[2] then return 0 end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+return 1`
 
 type redisClient interface {
 	Get(ctx context.Context, key string) *goredis.StringCmd
@@ -108,7 +121,17 @@ func (r *redis) Scan(ctx context.Context, table string, startKey string, count i
 	return nil, fmt.Errorf("scan is not supported")
 }
 
-func (r *redis) Update(ctx context.Context, table string, key string, values map[string][]byte) (err error) {
+func (r *redis) Update(ctx context.Context, table string, key string, values map[string][]byte) error {
+	return r.update(ctx, table, key, values, 0)
+}
+
+// UpdateWithTTL implements the ycsb.TTLDB interface.
+func (r *redis) UpdateWithTTL(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) error {
+	return r.update(ctx, table, key, values, ttl)
+}
+
+// update writes values to the record, which expires after ttl if ttl > 0.
+func (r *redis) update(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) (err error) {
 	// check if it's full update. If yes then we can avoid reading the previous value on string datype
 	fullUpdate := false
 	if int64(len(values)) == r.fieldcount {
@@ -123,6 +146,9 @@ func (r *redis) Update(ctx context.Context, table string, key string, values map
 			cmd := pipe.Do(ctx, JSON_SET, getKeyName(table, key), getFieldJsonPath(fieldName), jsonEscape(bytes))
 			cmds = append(cmds, cmd)
 		}
+		if ttl > 0 {
+			cmds = append(cmds, pipe.Do(ctx, PEXPIRE, getKeyName(table, key), ttl.Milliseconds()))
+		}
 		_, err = pipe.Exec(ctx)
 		if err != nil {
 			return
@@ -139,7 +165,7 @@ func (r *redis) Update(ctx context.Context, table string, key string, values map
 		for fieldName, bytes := range values {
 			args = append(args, fieldName, string(bytes))
 		}
-		err = r.client.Do(ctx, args...).Err()
+		err = r.doWithTTL(ctx, getKeyName(table, key), ttl, args...)
 	case STRING_DATATYPE:
 		fallthrough
 	default:
@@ -161,7 +187,7 @@ func (r *redis) Update(ctx context.Context, table string, key string, values map
 					return
 				}
 			}
-			return r.client.Set(ctx, getKeyName(table, key), string(encodedJson), 0).Err()
+			return r.client.Set(ctx, getKeyName(table, key), string(encodedJson), ttl).Err()
 		}
 	}
 	return
@@ -192,29 +218,90 @@ func getKeyName(table string, key string) string {
 	return table + "/" + key
 }
 
-func (r *redis) Insert(ctx context.Context, table string, key string, values map[string][]byte) (err error) {
+// doWithTTL runs a write command and, if ttl > 0, sets the expiry of the
+// written key in the same round trip.
+func (r *redis) doWithTTL(ctx context.Context, rkey string, ttl time.Duration, args ...interface{}) error {
+	if ttl <= 0 {
+		return r.client.Do(ctx, args...).Err()
+	}
+	pipe := r.client.Pipeline()
+	cmd := pipe.Do(ctx, args...)
+	expire := pipe.Do(ctx, PEXPIRE, rkey, ttl.Milliseconds())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	if err := cmd.Err(); err != nil {
+		return err
+	}
+	return expire.Err()
+}
+
+func (r *redis) Insert(ctx context.Context, table string, key string, values map[string][]byte) error {
+	return r.insert(ctx, table, key, values, 0)
+}
+
+// InsertWithTTL implements the ycsb.TTLDB interface.
+func (r *redis) InsertWithTTL(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) error {
+	return r.insert(ctx, table, key, values, ttl)
+}
+
+// insert writes a new record, which expires after ttl if ttl > 0.
+func (r *redis) insert(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) (err error) {
 	data, err := json.Marshal(values)
 	if err != nil {
 		return err
 	}
 	switch r.datatype {
 	case JSON_DATATYPE:
-		err = r.client.Do(ctx, JSON_SET, getKeyName(table, key), ".", string(data)).Err()
+		err = r.doWithTTL(ctx, getKeyName(table, key), ttl, JSON_SET, getKeyName(table, key), ".", string(data))
 	case HASH_DATATYPE:
 		args := make([]interface{}, 0, 2*len(values)+2)
 		args = append(args, HSET, getKeyName(table, key))
 		for fieldName, bytes := range values {
 			args = append(args, fieldName, string(bytes))
 		}
-		err = r.client.Do(ctx, args...).Err()
+		err = r.doWithTTL(ctx, getKeyName(table, key), ttl, args...)
 	case STRING_DATATYPE:
 		fallthrough
 	default:
-		err = r.client.Set(ctx, getKeyName(table, key), string(data), 0).Err()
+		err = r.client.Set(ctx, getKeyName(table, key), string(data), ttl).Err()
 	}
 	return
 }
 
+// hashRedis is the redis binding of the hash datatype, which increments,
+// appends to and compare-and-swaps fields natively. The string and json
+// datatypes store the fields of a row in one value, so the client emulates
+// these operations with a read and an update for them.
+type hashRedis struct {
+	*redis
+}
+
+// Increment implements the ycsb.AtomicCounterDB interface.
+func (r *hashRedis) Increment(ctx context.Context, table string, key string, field string, delta int64) (int64, error) {
+	return r.client.Do(ctx, HINCRBY, getKeyName(table, key), field, delta).Int64()
+}
+
+// Append implements the ycsb.AppendDB interface.
+func (r *hashRedis) Append(ctx context.Context, table string, key string, field string, data []byte) error {
+	return r.eval(ctx, appendScript, table, key, field, data)
+}
+
+// Prepend implements the ycsb.AppendDB interface.
+func (r *hashRedis) Prepend(ctx context.Context, table string, key string, field string, data []byte) error {
+	return r.eval(ctx, prependScript, table, key, field, data)
+}
+
+// CompareAndSwap implements the ycsb.CompareAndSwapDB interface.
+func (r *hashRedis) CompareAndSwap(ctx context.Context, table string, key string, field string, expected []byte, value []byte) (bool, error) {
+	swapped, err := r.client.Do(ctx, EVAL, casScript, 1, getKeyName(table, key), field, string(expected), string(value)).Int()
+	return swapped == 1, err
+}
+
+func (r *hashRedis) eval(ctx context.Context, script string, table string, key string, field string, data []byte) error {
+	return r.client.Do(ctx, EVAL, script, 1, getKeyName(table, key), field, string(data)).Err()
+}
+
 func (r *redis) Delete(ctx context.Context, table string, key string) error {
 	return r.client.Del(ctx, getKeyName(table, key)).Err()
 }
@@ -265,6 +352,9 @@ func (r redisCreator) Create(p *properties.Properties) (ycsb.DB, error) {
 	fmt.Println(fmt.Sprintf("Using the redis datatype: %s", rds.datatype))
 	rds.fieldcount = p.GetInt64(prop.FieldCount, prop.FieldCountDefault)
 
+	if rds.datatype == HASH_DATATYPE {
+		return &hashRedis{rds}, nil
+	}
 	return rds, nil
 }
 
@@ -14,7 +14,9 @@
 package tikv
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -321,3 +323,91 @@ func (db *txnDB) BatchDelete(ctx context.Context, table string, keys []string) e
 	}
 	return tx.Commit(ctx)
 }
+
+// Increment implements the ycsb.AtomicCounterDB interface. The read and the
+// write happen in one transaction, so concurrent increments of the same key
+// fail with a write conflict instead of losing updates.
+func (db *txnDB) Increment(ctx context.Context, table string, key string, field string, delta int64) (int64, error) {
+	var n int64
+	err := db.modifyField(ctx, table, key, field, func(old []byte) ([]byte, error) {
+		value, v, err := util.IncrementCounter(old, delta)
+		n = v
+		return value, err
+	})
+	return n, err
+}
+
+// Append implements the ycsb.AppendDB interface.
+func (db *txnDB) Append(ctx context.Context, table string, key string, field string, data []byte) error {
+	return db.modifyField(ctx, table, key, field, func(old []byte) ([]byte, error) {
+		return append(old, data...), nil
+	})
+}
+
+// Prepend implements the ycsb.AppendDB interface.
+func (db *txnDB) Prepend(ctx context.Context, table string, key string, field string, data []byte) error {
+	return db.modifyField(ctx, table, key, field, func(old []byte) ([]byte, error) {
+		return append(append([]byte{}, data...), old...), nil
+	})
+}
+
+// errValueChanged stops modifyField when a compare-and-swap doesn't match.
+var errValueChanged = errors.New("value changed")
+
+// CompareAndSwap implements the ycsb.CompareAndSwapDB interface. A write
+// conflict with a concurrent transaction is returned as an error.
+func (db *txnDB) CompareAndSwap(ctx context.Context, table string, key string, field string, expected []byte, value []byte) (bool, error) {
+	err := db.modifyField(ctx, table, key, field, func(old []byte) ([]byte, error) {
+		if !bytes.Equal(old, expected) {
+			return nil, errValueChanged
+		}
+		return value, nil
+	})
+	if err == errValueChanged {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// modifyField rewrites one field of a record in a single transaction. A
+// missing record is created.
+func (db *txnDB) modifyField(ctx context.Context, table string, key string, field string, modify func([]byte) ([]byte, error)) error {
+	rowKey := db.getRowKey(table, key)
+
+	tx, err := db.beginTxn()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	data := make(map[string][]byte)
+	row, err := tx.Get(ctx, rowKey)
+	if err != nil && !tikverr.IsErrNotFound(err) {
+		return err
+	}
+	if row != nil {
+		if data, err = db.r.Decode(row, nil); err != nil {
+			return err
+		}
+	}
+
+	if data[field], err = modify(data[field]); err != nil {
+		return err
+	}
+
+	buf := db.bufPool.Get()
+	defer func() {
+		db.bufPool.Put(buf)
+	}()
+
+	buf, err = db.r.Encode(buf, data)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Set(rowKey, buf); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
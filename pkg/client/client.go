@@ -29,18 +29,18 @@ import (
 )
 
 type worker struct {
-	p               *properties.Properties
-	workDB          ycsb.DB
-	workload        ycsb.Workload
-	doTransactions  bool
-	doBatch         bool
-	batchSize       int
-	opCount         int64
-	targetOpsPerMs  float64
-	threadID        int
-	targetOpsTickNs int64
-	opsDone         int64
-	keySize         int64
+	p              *properties.Properties
+	workDB         ycsb.DB
+	workload       ycsb.Workload
+	doTransactions bool
+	doBatch        bool
+	batchSize      int
+	opCount        int64
+	threadID       int
+	opsDone        int64
+	keySize        int64
+	// rate is the target rate of this thread, nil for unlimited
+	rate *rateCurve
 }
 
 func newWorker(p *properties.Properties, threadID int, threadCount int, workload ycsb.Workload, db ycsb.DB) *worker {
@@ -55,18 +55,11 @@ func newWorker(p *properties.Properties, threadID int, threadCount int, workload
 	w.workload = workload
 	w.workDB = db
 
-	var totalOpCount int64
-	if w.doTransactions {
-		totalOpCount = p.GetInt64(prop.OperationCount, 0)
-	} else {
-		if _, ok := p.Get(prop.InsertCount); ok {
-			totalOpCount = p.GetInt64(prop.InsertCount, 0)
-		} else {
-			totalOpCount = p.GetInt64(prop.RecordCount, 0)
-		}
-	}
+	totalOpCount := totalOpCount(p)
 
-	if totalOpCount < int64(threadCount) {
+	// A run without an operation count is limited by maxexecutiontime
+	unlimited := totalOpCount == 0 && p.GetInt64(prop.MaxExecutiontime, 0) > 0
+	if totalOpCount < int64(threadCount) && !unlimited {
 		fmt.Printf("totalOpCount(%s/%s/%s): %d should be bigger than threadCount: %d",
 			prop.OperationCount,
 			prop.InsertCount,
@@ -82,26 +75,28 @@ func newWorker(p *properties.Properties, threadID int, threadCount int, workload
 		w.opCount++
 	}
 
-	targetPerThreadPerms := float64(-1)
-	if v := p.GetInt64(prop.Target, 0); v > 0 {
-		targetPerThread := float64(v) / float64(threadCount)
-		targetPerThreadPerms = targetPerThread / 1000.0
-	}
-
-	if targetPerThreadPerms > 0 {
-		w.targetOpsPerMs = targetPerThreadPerms
-		w.targetOpsTickNs = int64(1000000.0 / w.targetOpsPerMs)
-	}
+	w.rate = newRateCurve(p, 1/float64(threadCount))
 
 	return w
 }
 
+// totalOpCount returns the number of operations of the run, over all threads.
+func totalOpCount(p *properties.Properties) int64 {
+	if p.GetBool(prop.DoTransactions, true) {
+		return p.GetInt64(prop.OperationCount, 0)
+	}
+	if _, ok := p.Get(prop.InsertCount); ok {
+		return p.GetInt64(prop.InsertCount, 0)
+	}
+	return p.GetInt64(prop.RecordCount, 0)
+}
+
 func (w *worker) throttle(ctx context.Context, startTime time.Time) {
-	if w.targetOpsPerMs <= 0 {
+	if w.rate == nil {
 		return
 	}
 
-	d := time.Duration(w.opsDone * w.targetOpsTickNs)
+	d := w.rate.offset(float64(w.opsDone))
 	d = startTime.Add(d).Sub(time.Now())
 	if d < 0 {
 		return
@@ -115,8 +110,8 @@ func (w *worker) throttle(ctx context.Context, startTime time.Time) {
 func (w *worker) run(ctx context.Context, startCh <-chan struct{}) {
 	<-startCh
 	// spread the thread operation out so they don't all hit the DB at the same time
-	if w.targetOpsPerMs > 0.0 && w.targetOpsPerMs <= 1.0 {
-		time.Sleep(time.Duration(rand.Int63n(w.targetOpsTickNs)))
+	if w.rate != nil && w.rate.start > 0 && w.rate.start <= 1000 {
+		time.Sleep(time.Duration(rand.Int63n(int64(float64(time.Second) / w.rate.start))))
 	}
 
 	runtime.GC()
@@ -126,20 +121,28 @@ func (w *worker) run(ctx context.Context, startCh <-chan struct{}) {
 	for w.opCount == 0 || w.opsDone < w.opCount {
 		var err error
 		opsCount := 1
+		opCtx := ctx
+		if w.rate != nil {
+			// The time at which throttle intended this operation to start
+			opCtx = measurement.WithScheduledStart(ctx, startTime.Add(w.rate.offset(float64(w.opsDone))))
+		}
+		measurement.AddInFlight(opCtx, 1)
 		if w.doTransactions {
 			if w.doBatch {
+				err = w.workload.DoBatchTransaction(opCtx, w.batchSize, w.workDB)
 				opsCount = w.batchSize
 			} else {
-				err = w.workload.DoTransaction(ctx, w.workDB)
+				err = w.workload.DoTransaction(opCtx, w.workDB)
 			}
 		} else {
 			if w.doBatch {
-				err = w.workload.DoBatchInsert(ctx, w.batchSize, w.workDB)
+				err = w.workload.DoBatchInsert(opCtx, w.batchSize, w.workDB)
 				opsCount = w.batchSize
 			} else {
-				err = w.workload.DoInsert(ctx, w.workDB)
+				err = w.workload.DoInsert(opCtx, w.workDB)
 			}
 		}
+		measurement.AddInFlight(opCtx, -1)
 
 		if err != nil && !w.p.GetBool(prop.Silence, prop.SilenceDefault) {
 			fmt.Printf("operation err: %v\n", err)
@@ -163,12 +166,34 @@ type Client struct {
 	p        *properties.Properties
 	workload ycsb.Workload
 	db       ycsb.DB
+	// openLoop drives the run unless the arrival mode is closed
+	openLoop *openLoop
+	// startDelay is the pause before the workers start
+	startDelay time.Duration
 }
 
 // NewClient returns a client with the given workload and DB.
 // The workload and db can't be nil.
 func NewClient(p *properties.Properties, workload ycsb.Workload, db ycsb.DB) *Client {
-	return &Client{p: p, workload: workload, db: db}
+	c := &Client{p: p, workload: workload, db: db, startDelay: 2 * time.Second}
+	if arrival := p.GetString(prop.Arrival, prop.ArrivalDefault); arrival != arrivalClosed {
+		c.openLoop = newOpenLoop(p, arrival)
+	}
+	return c
+}
+
+// SetStartDelay sets the pause before the workers start, 2s by default.
+func (c *Client) SetStartDelay(d time.Duration) {
+	c.startDelay = d
+}
+
+// OpenLoopStats returns the arrival counters of an open loop run, or false in
+// closed loop mode.
+func (c *Client) OpenLoopStats() (OpenLoopStats, bool) {
+	if c.openLoop == nil {
+		return OpenLoopStats{}, false
+	}
+	return c.openLoop.snapshot(), true
 }
 
 // Run runs the workload to the target DB, and blocks until all workers end.
@@ -186,7 +211,6 @@ func (c *Client) Run(origCtx context.Context) {
 	threadCount := c.p.GetInt(prop.ThreadCount, 1)
 	startWorkCh := make(chan struct{})
 
-	wg.Add(threadCount)
 	measureCtx, measureCancel := context.WithCancel(ctx)
 	measureCh := make(chan struct{}, 1)
 
@@ -213,11 +237,12 @@ func (c *Client) Run(origCtx context.Context) {
 			}
 		}
 
-		time.Sleep(2 * time.Second)
+		time.Sleep(c.startDelay)
 
 		close(startWorkCh)
 
 		measurement.EnableWarmUp(false)
+		measurement.StartInterval()
 		dur := c.p.GetInt64(prop.LogInterval, 10000)
 		t := time.NewTicker(time.Duration(dur) * time.Millisecond)
 		defer t.Stop()
@@ -225,29 +250,37 @@ func (c *Client) Run(origCtx context.Context) {
 		for {
 			select {
 			case <-t.C:
-				//measurement.Summary()
+				measurement.Interval()
 			case <-measureCtx.Done(): // will fire if timeout or client shutdown
+				// Report the last, partial interval
+				measurement.Interval()
 				return
 			}
 		}
 	}()
 
-	for i := 0; i < threadCount; i++ {
-		go func(threadId int) {
-			defer wg.Done()
+	if c.openLoop != nil {
+		c.openLoop.run(ctx, startWorkCh, threadCount, c.workload, c.db)
+		c.openLoop.printStats()
+	} else {
+		wg.Add(threadCount)
+		for i := 0; i < threadCount; i++ {
+			go func(threadId int) {
+				defer wg.Done()
 
-			w := newWorker(c.p, threadId, threadCount, c.workload, c.db)
-			threadCtx := c.workload.InitThread(ctx, threadId, threadCount)
-			threadCtx = c.db.InitThread(threadCtx, threadId, threadCount)
+				w := newWorker(c.p, threadId, threadCount, c.workload, c.db)
+				threadCtx := c.workload.InitThread(ctx, threadId, threadCount)
+				threadCtx = c.db.InitThread(threadCtx, threadId, threadCount)
 
-			w.run(threadCtx, startWorkCh) // your worker loop should respect threadCtx.Done()
+				w.run(threadCtx, startWorkCh) // your worker loop should respect threadCtx.Done()
 
-			c.db.CleanupThread(threadCtx)
-			c.workload.CleanupThread(threadCtx)
-		}(i)
-	}
+				c.db.CleanupThread(threadCtx)
+				c.workload.CleanupThread(threadCtx)
+			}(i)
+		}
 
-	wg.Wait()
+		wg.Wait()
+	}
 	if !c.p.GetBool(prop.DoTransactions, true) {
 		if analyzeDB, ok := c.db.(ycsb.AnalyzeDB); ok {
 			analyzeDB.Analyze(ctx, c.p.GetString(prop.TableName, prop.TableNameDefault))
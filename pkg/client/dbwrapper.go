@@ -14,35 +14,60 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"time"
 
 	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/util"
 	"github.com/pingcap/go-ycsb/pkg/ycsb"
 )
 
 // DbWrapper stores the pointer to a implementation of ycsb.DB.
 type DbWrapper struct {
 	DB ycsb.DB
+	// Recorder records every operation to a trace file if it is set
+	Recorder *TraceRecorder
 }
 
-func measure(start time.Time, op string, err error) {
-	lan := time.Now().Sub(start)
+func measure(ctx context.Context, start time.Time, op string, key string, err error) {
+	now := time.Now()
+	scheduled, throttled := measurement.ScheduledStart(ctx)
+	if throttled {
+		// Latency from the time the operation was scheduled
+		measureLatency(ctx, "INTENDED_"+op, "INTENDED_TOTAL", key, scheduled, now.Sub(scheduled), err)
+		if !measurement.MeasureActualLatency() {
+			return
+		}
+	}
+
+	measureLatency(ctx, op, "TOTAL", key, start, now.Sub(start), err)
+}
+
+func measureLatency(ctx context.Context, op string, total string, key string, start time.Time, lan time.Duration, err error) {
 	if err != nil {
-		measurement.Measure(fmt.Sprintf("%s_ERROR", op), start, lan)
+		measurement.MeasureContext(ctx, fmt.Sprintf("%s_ERROR", op), key, start, lan)
 		return
 	}
 
-	measurement.Measure(op, start, lan)
-	measurement.Measure("TOTAL", start, lan)
+	measurement.MeasureContext(ctx, op, key, start, lan)
+	measurement.MeasureContext(ctx, total, key, start, lan)
 }
 
 func (db DbWrapper) Close() error {
-	return db.DB.Close()
+	err := db.DB.Close()
+	if db.Recorder != nil {
+		if rerr := db.Recorder.Close(); err == nil {
+			err = rerr
+		}
+	}
+	return err
 }
 
 func (db DbWrapper) InitThread(ctx context.Context, threadID int, threadCount int) context.Context {
+	ctx = db.Recorder.withThread(ctx, threadID)
+	ctx = measurement.InitThread(ctx, threadID)
 	return db.DB.InitThread(ctx, threadID, threadCount)
 }
 
@@ -50,26 +75,30 @@ func (db DbWrapper) CleanupThread(ctx context.Context) {
 	db.DB.CleanupThread(ctx)
 }
 
-func (db DbWrapper) Read(ctx context.Context, table string, key string, fields []string) (_ map[string][]byte, err error) {
+func (db DbWrapper) Read(ctx context.Context, table string, key string, fields []string) (row map[string][]byte, err error) {
 	start := time.Now()
 	defer func() {
-		measure(start, "READ", err)
+		measure(ctx, start, "READ", key, err)
+		db.Recorder.record(ctx, start, "get", table, key, valuesSize(row), 0, recordResult(err))
 	}()
 
 	return db.DB.Read(ctx, table, key, fields)
 }
 
-func (db DbWrapper) BatchRead(ctx context.Context, table string, keys []string, fields []string) (_ []map[string][]byte, err error) {
+func (db DbWrapper) BatchRead(ctx context.Context, table string, keys []string, fields []string) (rows []map[string][]byte, err error) {
 	batchDB, ok := db.DB.(ycsb.BatchDB)
 	if ok {
 		start := time.Now()
 		defer func() {
-			measure(start, "BATCH_READ", err)
+			measure(ctx, start, "BATCH_READ", "", err)
+			db.recordBatch(ctx, start, "get", table, keys, rows, err)
 		}()
 		return batchDB.BatchRead(ctx, table, keys, fields)
 	}
 	for _, key := range keys {
-		_, err := db.DB.Read(ctx, table, key, fields)
+		start := time.Now()
+		row, err := db.DB.Read(ctx, table, key, fields)
+		db.Recorder.record(ctx, start, "get", table, key, valuesSize(row), 0, recordResult(err))
 		if err != nil {
 			return nil, err
 		}
@@ -80,7 +109,9 @@ func (db DbWrapper) BatchRead(ctx context.Context, table string, keys []string,
 func (db DbWrapper) Scan(ctx context.Context, table string, startKey string, count int, fields []string) (_ []map[string][]byte, err error) {
 	start := time.Now()
 	defer func() {
-		measure(start, "SCAN", err)
+		measure(ctx, start, "SCAN", startKey, err)
+		// The trace workload replays scans with the value size as the count
+		db.Recorder.record(ctx, start, "scan", table, startKey, count, 0, recordResult(err))
 	}()
 
 	return db.DB.Scan(ctx, table, startKey, count, fields)
@@ -89,7 +120,8 @@ func (db DbWrapper) Scan(ctx context.Context, table string, startKey string, cou
 func (db DbWrapper) Update(ctx context.Context, table string, key string, values map[string][]byte) (err error) {
 	start := time.Now()
 	defer func() {
-		measure(start, "UPDATE", err)
+		measure(ctx, start, "UPDATE", key, err)
+		db.Recorder.record(ctx, start, "set", table, key, valuesSize(values), 0, recordResult(err))
 	}()
 
 	return db.DB.Update(ctx, table, key, values)
@@ -100,12 +132,15 @@ func (db DbWrapper) BatchUpdate(ctx context.Context, table string, keys []string
 	if ok {
 		start := time.Now()
 		defer func() {
-			measure(start, "BATCH_UPDATE", err)
+			measure(ctx, start, "BATCH_UPDATE", "", err)
+			db.recordBatch(ctx, start, "set", table, keys, values, err)
 		}()
 		return batchDB.BatchUpdate(ctx, table, keys, values)
 	}
 	for i := range keys {
+		start := time.Now()
 		err := db.DB.Update(ctx, table, keys[i], values[i])
+		db.Recorder.record(ctx, start, "set", table, keys[i], valuesSize(values[i]), 0, recordResult(err))
 		if err != nil {
 			return err
 		}
@@ -116,7 +151,8 @@ func (db DbWrapper) BatchUpdate(ctx context.Context, table string, keys []string
 func (db DbWrapper) Insert(ctx context.Context, table string, key string, values map[string][]byte) (err error) {
 	start := time.Now()
 	defer func() {
-		measure(start, "INSERT", err)
+		measure(ctx, start, "INSERT", key, err)
+		db.Recorder.record(ctx, start, "add", table, key, valuesSize(values), 0, recordResult(err))
 	}()
 
 	return db.DB.Insert(ctx, table, key, values)
@@ -127,12 +163,15 @@ func (db DbWrapper) BatchInsert(ctx context.Context, table string, keys []string
 	if ok {
 		start := time.Now()
 		defer func() {
-			measure(start, "BATCH_INSERT", err)
+			measure(ctx, start, "BATCH_INSERT", "", err)
+			db.recordBatch(ctx, start, "add", table, keys, values, err)
 		}()
 		return batchDB.BatchInsert(ctx, table, keys, values)
 	}
 	for i := range keys {
+		start := time.Now()
 		err := db.DB.Insert(ctx, table, keys[i], values[i])
+		db.Recorder.record(ctx, start, "add", table, keys[i], valuesSize(values[i]), 0, recordResult(err))
 		if err != nil {
 			return err
 		}
@@ -140,10 +179,157 @@ func (db DbWrapper) BatchInsert(ctx context.Context, table string, keys []string
 	return nil
 }
 
+func (db DbWrapper) InsertWithTTL(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) (err error) {
+	ttlDB, ok := db.DB.(ycsb.TTLDB)
+	if !ok {
+		return db.Insert(ctx, table, key, values)
+	}
+	start := time.Now()
+	defer func() {
+		measure(ctx, start, "INSERT", key, err)
+		db.Recorder.record(ctx, start, "add", table, key, valuesSize(values), ttl, recordResult(err))
+	}()
+
+	return ttlDB.InsertWithTTL(ctx, table, key, values, ttl)
+}
+
+func (db DbWrapper) UpdateWithTTL(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) (err error) {
+	ttlDB, ok := db.DB.(ycsb.TTLDB)
+	if !ok {
+		return db.Update(ctx, table, key, values)
+	}
+	start := time.Now()
+	defer func() {
+		measure(ctx, start, "UPDATE", key, err)
+		db.Recorder.record(ctx, start, "set", table, key, valuesSize(values), ttl, recordResult(err))
+	}()
+
+	return ttlDB.UpdateWithTTL(ctx, table, key, values, ttl)
+}
+
+// Increment uses the native counter of the database if it has one. Otherwise it
+// is emulated with a read followed by an update, which is not atomic and is
+// measured as INCR_EMULATED.
+func (db DbWrapper) Increment(ctx context.Context, table string, key string, field string, delta int64) (_ int64, err error) {
+	start := time.Now()
+	op := "incr"
+	if delta < 0 {
+		op = "decr"
+	}
+	defer func() {
+		db.Recorder.record(ctx, start, op, table, key, 0, 0, recordResult(err))
+	}()
+	counterDB, ok := db.DB.(ycsb.AtomicCounterDB)
+	if ok {
+		defer func() {
+			measure(ctx, start, "INCR", key, err)
+		}()
+		return counterDB.Increment(ctx, table, key, field, delta)
+	}
+
+	defer func() {
+		measure(ctx, start, "INCR_EMULATED", key, err)
+	}()
+	row, err := db.DB.Read(ctx, table, key, []string{field})
+	if err != nil {
+		return 0, err
+	}
+	value, n, err := util.IncrementCounter(row[field], delta)
+	if err != nil {
+		return 0, err
+	}
+	return n, db.DB.Update(ctx, table, key, map[string][]byte{field: value})
+}
+
+// Append uses the native append of the database if it has one. Otherwise it is
+// emulated with a read followed by an update, measured as APPEND_EMULATED.
+func (db DbWrapper) Append(ctx context.Context, table string, key string, field string, data []byte) error {
+	return db.appendOrPrepend(ctx, table, key, field, data, false)
+}
+
+// Prepend is like Append but adds data in front of the value. Both are
+// measured as APPEND.
+func (db DbWrapper) Prepend(ctx context.Context, table string, key string, field string, data []byte) error {
+	return db.appendOrPrepend(ctx, table, key, field, data, true)
+}
+
+func (db DbWrapper) appendOrPrepend(ctx context.Context, table string, key string, field string, data []byte, prepend bool) (err error) {
+	start := time.Now()
+	defer func() {
+		op := "append"
+		if prepend {
+			op = "prepend"
+		}
+		db.Recorder.record(ctx, start, op, table, key, len(data), 0, recordResult(err))
+	}()
+	appendDB, ok := db.DB.(ycsb.AppendDB)
+	if ok {
+		defer func() {
+			measure(ctx, start, "APPEND", key, err)
+		}()
+		if prepend {
+			return appendDB.Prepend(ctx, table, key, field, data)
+		}
+		return appendDB.Append(ctx, table, key, field, data)
+	}
+
+	defer func() {
+		measure(ctx, start, "APPEND_EMULATED", key, err)
+	}()
+	row, err := db.DB.Read(ctx, table, key, []string{field})
+	if err != nil {
+		return err
+	}
+	value := make([]byte, 0, len(row[field])+len(data))
+	if prepend {
+		value = append(append(value, data...), row[field]...)
+	} else {
+		value = append(append(value, row[field]...), data...)
+	}
+	return db.DB.Update(ctx, table, key, map[string][]byte{field: value})
+}
+
+// CompareAndSwap uses the native compare-and-swap of the database if it has
+// one. Otherwise it is emulated with a read followed by an update, which is not
+// atomic and is measured as CAS_EMULATED. Swaps that don't happen because the
+// value changed are measured as CAS_FAILED (or CAS_EMULATED_FAILED), apart from
+// errors.
+func (db DbWrapper) CompareAndSwap(ctx context.Context, table string, key string, field string, expected []byte, value []byte) (swapped bool, err error) {
+	start := time.Now()
+	op := "CAS"
+	casDB, ok := db.DB.(ycsb.CompareAndSwapDB)
+	if !ok {
+		op = "CAS_EMULATED"
+	}
+	defer func() {
+		if err == nil && !swapped {
+			measure(ctx, start, op+"_FAILED", key, nil)
+			db.Recorder.record(ctx, start, "cas", table, key, len(value), 0, recordResultFailed)
+			return
+		}
+		measure(ctx, start, op, key, err)
+		db.Recorder.record(ctx, start, "cas", table, key, len(value), 0, recordResult(err))
+	}()
+
+	if ok {
+		return casDB.CompareAndSwap(ctx, table, key, field, expected, value)
+	}
+
+	row, err := db.DB.Read(ctx, table, key, []string{field})
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(row[field], expected) {
+		return false, nil
+	}
+	return true, db.DB.Update(ctx, table, key, map[string][]byte{field: value})
+}
+
 func (db DbWrapper) Delete(ctx context.Context, table string, key string) (err error) {
 	start := time.Now()
 	defer func() {
-		measure(start, "DELETE", err)
+		measure(ctx, start, "DELETE", key, err)
+		db.Recorder.record(ctx, start, "delete", table, key, 0, 0, recordResult(err))
 	}()
 
 	return db.DB.Delete(ctx, table, key)
@@ -154,12 +340,15 @@ func (db DbWrapper) BatchDelete(ctx context.Context, table string, keys []string
 	if ok {
 		start := time.Now()
 		defer func() {
-			measure(start, "BATCH_DELETE", err)
+			measure(ctx, start, "BATCH_DELETE", "", err)
+			db.recordBatch(ctx, start, "delete", table, keys, nil, err)
 		}()
 		return batchDB.BatchDelete(ctx, table, keys)
 	}
 	for _, key := range keys {
+		start := time.Now()
 		err := db.DB.Delete(ctx, table, key)
+		db.Recorder.record(ctx, start, "delete", table, key, 0, 0, recordResult(err))
 		if err != nil {
 			return err
 		}
@@ -167,6 +356,18 @@ func (db DbWrapper) BatchDelete(ctx context.Context, table string, keys []string
 	return nil
 }
 
+// recordBatch records a batch operation as one operation per key, with the
+// size of the values written or read.
+func (db DbWrapper) recordBatch(ctx context.Context, start time.Time, op string, table string, keys []string, values []map[string][]byte, err error) {
+	for i, key := range keys {
+		size := 0
+		if i < len(values) {
+			size = valuesSize(values[i])
+		}
+		db.Recorder.record(ctx, start, op, table, key, size, 0, recordResult(err))
+	}
+}
+
 func (db DbWrapper) Analyze(ctx context.Context, table string) error {
 	if analyzeDB, ok := db.DB.(ycsb.AnalyzeDB); ok {
 		return analyzeDB.Analyze(ctx, table)
@@ -174,6 +375,15 @@ func (db DbWrapper) Analyze(ctx context.Context, table string) error {
 	return nil
 }
 
+// Stats returns the statistics of the underlying DB, or nil if it doesn't
+// report any.
+func (db DbWrapper) Stats(ctx context.Context) (map[string]interface{}, error) {
+	if statsDB, ok := db.DB.(ycsb.StatsDB); ok {
+		return statsDB.Stats(ctx)
+	}
+	return nil, nil
+}
+
 func (db DbWrapper) ResetStats(ctx context.Context) error {
 	if resettable, ok := db.DB.(interface {
 		ResetStats(context.Context) error
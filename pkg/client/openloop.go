@@ -0,0 +1,279 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/ycsb"
+)
+
+// Arrival processes
+const (
+	arrivalClosed   = "closed"
+	arrivalConstant = "constant"
+	arrivalPoisson  = "poisson"
+	arrivalBursty   = "bursty"
+)
+
+// arrivalProcess generates the arrival times of an open loop run.
+type arrivalProcess interface {
+	// next returns the time of the next arrival since the start of the run.
+	next() time.Duration
+}
+
+// constantArrivals arrive at the exact times the rate gives.
+type constantArrivals struct {
+	rate *rateCurve
+	n    int64
+}
+
+func (a *constantArrivals) next() time.Duration {
+	t := a.rate.offset(float64(a.n))
+	a.n++
+	return t
+}
+
+// poissonArrivals have exponentially distributed gaps, scaled to the rate at
+// the time of the arrival.
+type poissonArrivals struct {
+	rate *rateCurve
+	n    float64 // expected number of arrivals so far
+	r    *rand.Rand
+}
+
+func (a *poissonArrivals) next() time.Duration {
+	t := a.rate.offset(a.n)
+	a.n += a.r.ExpFloat64()
+	return t
+}
+
+// burstyArrivals alternate between on periods with Poisson arrivals and off
+// periods without any. The rate during on periods is raised so that the mean
+// rate over a whole cycle is the target.
+type burstyArrivals struct {
+	poisson poissonArrivals // arrivals in on time
+	on, off float64         // sec
+}
+
+func (a *burstyArrivals) next() time.Duration {
+	onTime := a.poisson.next().Seconds()
+	cycles := math.Floor(onTime / a.on)
+	t := cycles*(a.on+a.off) + onTime - cycles*a.on
+	return time.Duration(t * float64(time.Second))
+}
+
+func newArrivalProcess(p *properties.Properties, arrival string, rate *rateCurve) (arrivalProcess, error) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	switch arrival {
+	case arrivalConstant:
+		return &constantArrivals{rate: rate}, nil
+	case arrivalPoisson:
+		return &poissonArrivals{rate: rate, r: r}, nil
+	case arrivalBursty:
+		on := p.GetFloat64(prop.ArrivalBurstOn, prop.ArrivalBurstOnDefault) / 1000
+		off := p.GetFloat64(prop.ArrivalBurstOff, prop.ArrivalBurstOffDefault) / 1000
+		if on <= 0 || off < 0 {
+			return nil, fmt.Errorf("invalid %s %v and %s %v", prop.ArrivalBurstOn, on*1000, prop.ArrivalBurstOff, off*1000)
+		}
+		// A ramp lasts for the on time within its duration
+		cycle := (on + off) / on
+		return &burstyArrivals{
+			poisson: poissonArrivals{rate: rate.scale(cycle, cycle), r: r},
+			on:      on,
+			off:     off,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported %s %s, must be one of %s, %s, %s or %s", prop.Arrival, arrival,
+			arrivalClosed, arrivalConstant, arrivalPoisson, arrivalBursty)
+	}
+}
+
+// OpenLoopStats counts the arrivals of an open loop run. With a batch.size
+// above 1 each arrival is a batch.
+type OpenLoopStats struct {
+	// Arrivals is the number of operations generated
+	Arrivals int64
+	// Dropped is the number of arrivals discarded because every goroutine was
+	// busy and the queue was full
+	Dropped int64
+	// InFlight is the number of operations queued or running
+	InFlight int64
+	// MaxInFlight is the highest InFlight seen
+	MaxInFlight int64
+}
+
+// openLoop issues operations at the times generated by an arrival process,
+// independently of how fast the database completes them, and dispatches them
+// to a fixed pool of goroutines. Only the arrivals which are not dropped count
+// toward the operation count.
+type openLoop struct {
+	p         *properties.Properties
+	process   arrivalProcess
+	opCount   int64
+	batchSize int
+	queue     int
+	doInsert  bool
+	stats     OpenLoopStats
+}
+
+func newOpenLoop(p *properties.Properties, arrival string) *openLoop {
+	batchSize := p.GetInt(prop.BatchSize, prop.DefaultBatchSize)
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	// Batches arrive at the target rate of operations divided by their size
+	rate := newRateCurve(p, 1/float64(batchSize))
+	if rate == nil {
+		fmt.Printf("%s %s needs a %s rate\n", prop.Arrival, arrival, prop.Target)
+		os.Exit(-1)
+	}
+	process, err := newArrivalProcess(p, arrival, rate)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	queue := p.GetInt(prop.ArrivalQueue, -1)
+	if queue < 0 {
+		// Absorb the arrivals released together when the generator wakes up late
+		queue = p.GetInt(prop.ThreadCount, 1)
+	}
+	return &openLoop{
+		p:         p,
+		process:   process,
+		opCount:   totalOpCount(p),
+		batchSize: batchSize,
+		queue:     queue,
+		doInsert:  !p.GetBool(prop.DoTransactions, true),
+	}
+}
+
+// run generates arrivals until the operation count is reached or ctx is done,
+// and blocks until the dispatched operations have finished.
+func (o *openLoop) run(ctx context.Context, startCh <-chan struct{}, threadCount int, workload ycsb.Workload, db ycsb.DB) {
+	arrivals := make(chan time.Time, o.queue)
+
+	var wg sync.WaitGroup
+	wg.Add(threadCount)
+	for i := 0; i < threadCount; i++ {
+		go func(threadID int) {
+			defer wg.Done()
+
+			threadCtx := workload.InitThread(ctx, threadID, threadCount)
+			threadCtx = db.InitThread(threadCtx, threadID, threadCount)
+			defer func() {
+				db.CleanupThread(threadCtx)
+				workload.CleanupThread(threadCtx)
+			}()
+
+			for at := range arrivals {
+				o.do(measurement.WithScheduledStart(threadCtx, at), workload, db)
+				atomic.AddInt64(&o.stats.InFlight, -1)
+			}
+		}(i)
+	}
+
+	<-startCh
+	o.generate(ctx, arrivals)
+	close(arrivals)
+	wg.Wait()
+}
+
+func (o *openLoop) do(ctx context.Context, workload ycsb.Workload, db ycsb.DB) {
+	var err error
+	measurement.AddInFlight(ctx, 1)
+	switch {
+	case o.doInsert && o.batchSize > 1:
+		err = workload.DoBatchInsert(ctx, o.batchSize, db)
+	case o.doInsert:
+		err = workload.DoInsert(ctx, db)
+	case o.batchSize > 1:
+		err = workload.DoBatchTransaction(ctx, o.batchSize, db)
+	default:
+		err = workload.DoTransaction(ctx, db)
+	}
+	measurement.AddInFlight(ctx, -1)
+	if err != nil && !o.p.GetBool(prop.Silence, prop.SilenceDefault) {
+		fmt.Printf("operation err: %v\n", err)
+	}
+}
+
+func (o *openLoop) generate(ctx context.Context, arrivals chan<- time.Time) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	start := time.Now()
+	// Only this goroutine updates Arrivals and Dropped
+	for o.opCount == 0 || (o.stats.Arrivals-o.stats.Dropped)*int64(o.batchSize) < o.opCount {
+		at := start.Add(o.process.next())
+		// When the generator is behind, arrivals are dispatched at once but
+		// still measured from their scheduled time
+		if d := time.Until(at); d > 0 {
+			timer.Reset(d)
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+
+		atomic.AddInt64(&o.stats.Arrivals, 1)
+		inFlight := atomic.AddInt64(&o.stats.InFlight, 1)
+		select {
+		case arrivals <- at:
+			for {
+				seen := atomic.LoadInt64(&o.stats.MaxInFlight)
+				if inFlight <= seen || atomic.CompareAndSwapInt64(&o.stats.MaxInFlight, seen, inFlight) {
+					break
+				}
+			}
+		default:
+			atomic.AddInt64(&o.stats.InFlight, -1)
+			atomic.AddInt64(&o.stats.Dropped, 1)
+		}
+	}
+}
+
+func (o *openLoop) printStats() {
+	stats := o.snapshot()
+	dropped := float64(0)
+	if stats.Arrivals > 0 {
+		dropped = float64(stats.Dropped) * 100 / float64(stats.Arrivals)
+	}
+	fmt.Printf("Open loop: %d arrivals, %d dropped (%.2f%%), max in flight %d\n",
+		stats.Arrivals, stats.Dropped, dropped, stats.MaxInFlight)
+}
+
+// snapshot returns a copy of the arrival counters.
+func (o *openLoop) snapshot() OpenLoopStats {
+	return OpenLoopStats{
+		Arrivals:    atomic.LoadInt64(&o.stats.Arrivals),
+		Dropped:     atomic.LoadInt64(&o.stats.Dropped),
+		InFlight:    atomic.LoadInt64(&o.stats.InFlight),
+		MaxInFlight: atomic.LoadInt64(&o.stats.MaxInFlight),
+	}
+}
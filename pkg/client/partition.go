@@ -0,0 +1,67 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"strconv"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+// Partition returns the properties of each of n clients which together run
+// the benchmark of p. Each client inserts, and with the core workload
+// accesses, its own part of the key range [insertstart, insertstart +
+// insertcount), and does its share of operationcount and of the target
+// throughput.
+func Partition(p *properties.Properties, n int) []*properties.Properties {
+	recordCount := p.GetInt64(prop.RecordCount, prop.RecordCountDefault)
+	insertStart := p.GetInt64(prop.InsertStart, prop.InsertStartDefault)
+	insertCount := p.GetInt64(prop.InsertCount, recordCount-insertStart)
+
+	parts := make([]*properties.Properties, n)
+	for i := range parts {
+		part := properties.NewProperties()
+		part.Merge(p)
+
+		start, count := share(insertCount, n, i)
+		part.Set(prop.InsertStart, strconv.FormatInt(insertStart+start, 10))
+		part.Set(prop.InsertCount, strconv.FormatInt(count, 10))
+		for _, key := range []string{prop.OperationCount, prop.Target, prop.TargetEnd} {
+			if _, ok := p.Get(key); ok {
+				total := p.GetInt64(key, 0)
+				_, count := share(total, n, i)
+				if total > 0 && count == 0 {
+					// 0 would mean unlimited
+					count = 1
+				}
+				part.Set(key, strconv.FormatInt(count, 10))
+			}
+		}
+		parts[i] = part
+	}
+	return parts
+}
+
+// share returns the offset and size of the i-th of n near-equal parts of
+// total.
+func share(total int64, n int, i int) (int64, int64) {
+	size := total / int64(n)
+	rem := total % int64(n)
+	offset := int64(i)*size + min(int64(i), rem)
+	if int64(i) < rem {
+		size++
+	}
+	return offset, size
+}
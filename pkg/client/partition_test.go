@@ -0,0 +1,67 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+func TestShare(t *testing.T) {
+	tests := []struct {
+		total int64
+		n     int
+		// offsets and sizes of the parts
+		offsets []int64
+		sizes   []int64
+	}{
+		{total: 10, n: 1, offsets: []int64{0}, sizes: []int64{10}},
+		{total: 10, n: 2, offsets: []int64{0, 5}, sizes: []int64{5, 5}},
+		{total: 10, n: 3, offsets: []int64{0, 4, 7}, sizes: []int64{4, 3, 3}},
+		{total: 11, n: 4, offsets: []int64{0, 3, 6, 9}, sizes: []int64{3, 3, 3, 2}},
+		{total: 2, n: 3, offsets: []int64{0, 1, 2}, sizes: []int64{1, 1, 0}},
+	}
+	for _, tt := range tests {
+		for i := 0; i < tt.n; i++ {
+			offset, size := share(tt.total, tt.n, i)
+			if offset != tt.offsets[i] || size != tt.sizes[i] {
+				t.Errorf("share(%d, %d, %d): want %d+%d, got %d+%d",
+					tt.total, tt.n, i, tt.offsets[i], tt.sizes[i], offset, size)
+			}
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	p := properties.LoadMap(map[string]string{
+		prop.RecordCount:    "100",
+		prop.InsertStart:    "10",
+		prop.OperationCount: "1001",
+		prop.Target:         "2",
+		prop.ThreadCount:    "8",
+	})
+	parts := Partition(p, 3)
+	if len(parts) != 3 {
+		t.Fatalf("want 3 parts, got %d", len(parts))
+	}
+
+	want := []map[string]string{
+		{prop.InsertStart: "10", prop.InsertCount: "30", prop.OperationCount: "334", prop.Target: "1"},
+		{prop.InsertStart: "40", prop.InsertCount: "30", prop.OperationCount: "334", prop.Target: "1"},
+		// A target of 0 would be unlimited
+		{prop.InsertStart: "70", prop.InsertCount: "30", prop.OperationCount: "333", prop.Target: "1"},
+	}
+	for i, part := range parts {
+		for key, value := range want[i] {
+			if got := part.GetString(key, ""); got != value {
+				t.Errorf("part %d: want %s=%s, got %s", i, key, value, got)
+			}
+		}
+		if got := part.GetString(prop.ThreadCount, ""); got != "8" {
+			t.Errorf("part %d: want the thread count kept, got %s", i, got)
+		}
+		if _, ok := part.Get(prop.TargetEnd); ok {
+			t.Errorf("part %d: want no %s", i, prop.TargetEnd)
+		}
+	}
+}
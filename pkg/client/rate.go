@@ -0,0 +1,71 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"math"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+// rateCurve is a target rate which ramps linearly from start to end over
+// duration and stays at end afterwards.
+type rateCurve struct {
+	start, end float64 // ops/sec
+	duration   float64 // sec
+}
+
+// newRateCurve returns the target rate of the run multiplied by scale, or nil
+// if the run is not throttled. The rate ramps to target.end over
+// maxexecutiontime if both are set.
+func newRateCurve(p *properties.Properties, scale float64) *rateCurve {
+	c := &rateCurve{start: float64(p.GetInt64(prop.Target, 0)) * scale}
+	c.end = c.start
+	if _, ok := p.Get(prop.TargetEnd); ok {
+		c.duration = float64(p.GetInt64(prop.MaxExecutiontime, 0))
+		if c.duration > 0 {
+			c.end = float64(p.GetInt64(prop.TargetEnd, 0)) * scale
+		}
+	}
+	if c.start < 0 || c.end < 0 || (c.start == 0 && c.end == 0) {
+		return nil
+	}
+	return c
+}
+
+// offset returns the time since the start of the run at which n operations
+// are due.
+func (c *rateCurve) offset(n float64) time.Duration {
+	var t float64
+	if c.end == c.start {
+		t = n / c.start
+	} else if total := (c.start + c.end) / 2 * c.duration; n <= total {
+		// n = start*t + slope*t^2/2
+		slope := (c.end - c.start) / c.duration
+		t = (math.Sqrt(c.start*c.start+2*slope*n) - c.start) / slope
+	} else if c.end > 0 {
+		t = c.duration + (n-total)/c.end
+	} else {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(t * float64(time.Second))
+}
+
+// scale returns the curve with the rates multiplied by f and the duration
+// divided by g.
+func (c *rateCurve) scale(f float64, g float64) *rateCurve {
+	return &rateCurve{start: c.start * f, end: c.end * f, duration: c.duration / g}
+}
@@ -0,0 +1,212 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Trace recorder formats, both readable by the trace workload
+const (
+	// RecordFormatTwemcache writes the twemcache CSV columns followed by the
+	// table and the result
+	RecordFormatTwemcache = "twemcache"
+	// RecordFormatJSONLines writes one JSON object per operation with the
+	// table and result as extra fields
+	RecordFormatJSONLines = "jsonl"
+)
+
+// Results of recorded operations
+const (
+	recordResultOK     = "ok"
+	recordResultError  = "error"
+	recordResultFailed = "failed" // compare-and-swap that didn't swap
+)
+
+type recorderContextKey string
+
+const recorderThreadKey = recorderContextKey("recorderThread")
+
+// TraceRecorder writes the operations issued through a DbWrapper to a trace
+// file, so that the run can be replayed with the trace workload. Operations
+// are written in the order they complete, with the time they started.
+type TraceRecorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *zstd.Encoder
+	w       *bufio.Writer
+	jsonl   bool
+	start   time.Time
+	buf     []byte
+	err     error
+}
+
+// NewTraceRecorder creates the trace file, zstd compressed if its name ends
+// in .zst or .zstd.
+func NewTraceRecorder(path string, format string) (*TraceRecorder, error) {
+	if format != RecordFormatTwemcache && format != RecordFormatJSONLines {
+		return nil, fmt.Errorf("unknown trace record format '%s', must be '%s' or '%s'", format, RecordFormatTwemcache, RecordFormatJSONLines)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace file: %w", err)
+	}
+	r := &TraceRecorder{
+		file:  file,
+		jsonl: format == RecordFormatJSONLines,
+		start: time.Now(),
+	}
+	if strings.HasSuffix(path, ".zst") || strings.HasSuffix(path, ".zstd") {
+		if r.encoder, err = zstd.NewWriter(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+		}
+		r.w = bufio.NewWriterSize(r.encoder, 64*1024)
+	} else {
+		r.w = bufio.NewWriterSize(file, 64*1024)
+	}
+	return r, nil
+}
+
+// withThread stores the thread id which is recorded as the client id.
+func (r *TraceRecorder) withThread(ctx context.Context, threadID int) context.Context {
+	if r == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, recorderThreadKey, threadID)
+}
+
+// traceJSONRecord is one operation in the jsonl format.
+type traceJSONRecord struct {
+	Timestamp float64 `json:"timestamp"`
+	Key       string  `json:"key"`
+	KeySize   int     `json:"key_size"`
+	ValueSize int     `json:"value_size"`
+	ClientID  string  `json:"client_id"`
+	Op        string  `json:"op"`
+	TTL       int     `json:"ttl"`
+	Table     string  `json:"table"`
+	Result    string  `json:"result"`
+}
+
+// record writes one operation. op is the memcached command the trace workload
+// replays it with. A nil recorder records nothing.
+func (r *TraceRecorder) record(ctx context.Context, start time.Time, op string, table string, key string, valueSize int, ttl time.Duration, result string) {
+	if r == nil {
+		return
+	}
+	client := "0"
+	if threadID, ok := ctx.Value(recorderThreadKey).(int); ok {
+		client = strconv.Itoa(threadID)
+	}
+	timestamp := start.Sub(r.start).Seconds()
+	// Round TTLs up to whole seconds so that they don't disappear
+	ttlSeconds := int((ttl + time.Second - 1) / time.Second)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+
+	b := r.buf[:0]
+	if r.jsonl {
+		data, err := json.Marshal(traceJSONRecord{
+			Timestamp: timestamp,
+			Key:       key,
+			KeySize:   len(key),
+			ValueSize: valueSize,
+			ClientID:  client,
+			Op:        op,
+			TTL:       ttlSeconds,
+			Table:     table,
+			Result:    result,
+		})
+		if err != nil {
+			r.err = err
+			return
+		}
+		b = append(append(b, data...), '\n')
+	} else {
+		// Keys and tables written by go-ycsb never need CSV quoting
+		b = strconv.AppendFloat(b, timestamp, 'f', 6, 64)
+		b = append(b, ',')
+		b = append(b, key...)
+		b = append(b, ',')
+		b = strconv.AppendInt(b, int64(len(key)), 10)
+		b = append(b, ',')
+		b = strconv.AppendInt(b, int64(valueSize), 10)
+		b = append(b, ',')
+		b = append(b, client...)
+		b = append(b, ',')
+		b = append(b, op...)
+		b = append(b, ',')
+		b = strconv.AppendInt(b, int64(ttlSeconds), 10)
+		b = append(b, ',')
+		b = append(b, table...)
+		b = append(b, ',')
+		b = append(b, result...)
+		b = append(b, '\n')
+	}
+	r.buf = b
+	_, r.err = r.w.Write(b)
+}
+
+// Close flushes the trace and closes the file.
+func (r *TraceRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.err
+	if ferr := r.w.Flush(); err == nil {
+		err = ferr
+	}
+	if r.encoder != nil {
+		if cerr := r.encoder.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// recordResult returns the recorded result of an operation.
+func recordResult(err error) string {
+	if err != nil {
+		return recordResultError
+	}
+	return recordResultOK
+}
+
+// valuesSize returns the total size of the field values of a record.
+func valuesSize(values map[string][]byte) int {
+	n := 0
+	for _, v := range values {
+		n += len(v)
+	}
+	return n
+}
@@ -0,0 +1,170 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/ycsb"
+)
+
+// Phase keys which are not plain property overrides
+const (
+	phaseDuration = "duration"
+)
+
+// clientProps are read by the client for every phase, so overriding them
+// doesn't need a new workload.
+var clientProps = map[string]bool{
+	prop.ThreadCount:      true,
+	prop.Target:           true,
+	prop.TargetEnd:        true,
+	prop.MaxExecutiontime: true,
+	prop.OperationCount:   true,
+	prop.WarmUpTime:       true,
+	prop.Arrival:          true,
+	prop.ArrivalQueue:     true,
+	prop.ArrivalBurstOn:   true,
+	prop.ArrivalBurstOff:  true,
+	prop.Silence:          true,
+}
+
+// Phase is one part of a run schedule.
+type Phase struct {
+	Name string
+	// Props are the properties of the run with the overrides of the phase
+	Props *properties.Properties
+	// NewWorkload is set if the phase overrides properties of the workload,
+	// which then has to be created again for the phase
+	NewWorkload bool
+}
+
+// ParseSchedule returns the phases listed by the schedule property, or nil if
+// it is not set. Every property schedule.<phase>.<name> overrides <name> in
+// that phase, except for:
+//   - schedule.<phase>.duration: the length of the phase in whole seconds, as
+//     a Go duration or a number. The phase runs for this long instead of for
+//     operationcount operations.
+//   - schedule.<phase>.target: either a rate, or start-end for a linear ramp
+//     over the phase.
+func ParseSchedule(p *properties.Properties) ([]Phase, error) {
+	names := p.GetString(prop.Schedule, "")
+	if names == "" {
+		return nil, nil
+	}
+
+	var phases []Phase
+	for i, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		phase := Phase{Name: name, Props: properties.NewProperties()}
+		phase.Props.Merge(p)
+		if i > 0 {
+			// Warm-up only happens before the first phase
+			phase.Props.Set(prop.WarmUpTime, "0")
+		}
+
+		overrides := p.FilterStripPrefix(fmt.Sprintf("%s.%s.", prop.Schedule, name))
+		if overrides.Len() == 0 {
+			return nil, fmt.Errorf("phase %s of the schedule has no properties", name)
+		}
+		for _, key := range overrides.Keys() {
+			value := overrides.MustGetString(key)
+			switch key {
+			case phaseDuration:
+				d, err := time.ParseDuration(value)
+				if err != nil {
+					seconds, serr := strconv.ParseInt(value, 10, 64)
+					if serr != nil {
+						return nil, fmt.Errorf("invalid duration %s of phase %s", value, name)
+					}
+					d = time.Duration(seconds) * time.Second
+				}
+				// maxexecutiontime is in whole seconds
+				if d < time.Second || d%time.Second != 0 {
+					return nil, fmt.Errorf("duration %s of phase %s must be a positive whole number of seconds", value, name)
+				}
+				phase.Props.Set(prop.MaxExecutiontime, strconv.FormatInt(int64(d/time.Second), 10))
+				if _, ok := overrides.Get(prop.OperationCount); !ok {
+					phase.Props.Set(prop.OperationCount, "0")
+				}
+			case prop.Target:
+				start, end, ramp := strings.Cut(value, "-")
+				phase.Props.Set(prop.Target, strings.TrimSpace(start))
+				if ramp {
+					phase.Props.Set(prop.TargetEnd, strings.TrimSpace(end))
+				} else {
+					phase.Props.Delete(prop.TargetEnd)
+				}
+			default:
+				phase.Props.Set(key, value)
+				if !clientProps[key] {
+					phase.NewWorkload = true
+				}
+			}
+		}
+		if _, ok := overrides.Get(phaseDuration); !ok && phase.Props.GetInt64(prop.OperationCount, 0) == 0 {
+			return nil, fmt.Errorf("phase %s of the schedule needs a duration or an operationcount", name)
+		}
+		phases = append(phases, phase)
+	}
+	return phases, nil
+}
+
+// RunSchedule runs the phases back to back against db, printing the
+// measurements of each phase when it ends. Phases which override workload
+// properties run with a new workload instead of the given one. The caller
+// closes the measurements with measurement.Close after it.
+func RunSchedule(ctx context.Context, phases []Phase, workload ycsb.Workload, db ycsb.DB) error {
+	for _, phase := range phases {
+		phaseWorkload := workload
+		if phase.NewWorkload {
+			name := phase.Props.GetString(prop.Workload, "core")
+			creator := ycsb.GetWorkloadCreator(name)
+			if creator == nil {
+				return fmt.Errorf("workload %s is not registered", name)
+			}
+			var err error
+			if phaseWorkload, err = creator.Create(phase.Props); err != nil {
+				return fmt.Errorf("create workload %s for phase %s failed: %w", name, phase.Name, err)
+			}
+		}
+
+		fmt.Printf("Starting phase %s\n", phase.Name)
+		pc := NewClient(phase.Props, phaseWorkload, db)
+		// Phases start right away so that their duration is all spent running
+		pc.startDelay = 0
+		start := time.Now()
+		pc.Run(ctx)
+		fmt.Printf("Phase %s finished, takes %s\n", phase.Name, time.Since(start))
+		measurement.OutputPhase(phase.Name)
+
+		if phase.NewWorkload {
+			phaseWorkload.Close()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
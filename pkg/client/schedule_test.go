@@ -0,0 +1,303 @@
+package client
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/ycsb"
+)
+
+func TestParseSchedule(t *testing.T) {
+	type phaseWant struct {
+		name        string
+		props       map[string]string // "" means unset
+		newWorkload bool
+	}
+	tests := []struct {
+		name   string
+		props  map[string]string
+		phases []phaseWant
+		err    bool
+	}{
+		{
+			name:  "no schedule",
+			props: map[string]string{prop.OperationCount: "10"},
+		},
+		{
+			name: "durations",
+			props: map[string]string{
+				prop.Schedule:            "a, b",
+				prop.OperationCount:      "10",
+				prop.WarmUpTime:          "5",
+				"schedule.a.duration":    "1m30s",
+				"schedule.b.duration":    "20",
+				"schedule.b.threadcount": "8",
+			},
+			phases: []phaseWant{
+				{name: "a", props: map[string]string{prop.MaxExecutiontime: "90", prop.OperationCount: "0", prop.WarmUpTime: "5"}},
+				{name: "b", props: map[string]string{prop.MaxExecutiontime: "20", prop.OperationCount: "0", prop.WarmUpTime: "0", prop.ThreadCount: "8"}},
+			},
+		},
+		{
+			name: "duration with operation count",
+			props: map[string]string{
+				prop.Schedule:               "a",
+				"schedule.a.duration":       "2s",
+				"schedule.a.operationcount": "100",
+			},
+			phases: []phaseWant{
+				{name: "a", props: map[string]string{prop.MaxExecutiontime: "2", prop.OperationCount: "100"}},
+			},
+		},
+		{
+			name: "ramp",
+			props: map[string]string{
+				prop.Schedule:         "a,b",
+				prop.TargetEnd:        "50",
+				"schedule.a.duration": "10",
+				"schedule.a.target":   "100 - 2000",
+				"schedule.b.duration": "10",
+				"schedule.b.target":   "300",
+			},
+			phases: []phaseWant{
+				{name: "a", props: map[string]string{prop.Target: "100", prop.TargetEnd: "2000"}},
+				{name: "b", props: map[string]string{prop.Target: "300", prop.TargetEnd: ""}},
+			},
+		},
+		{
+			name: "workload override",
+			props: map[string]string{
+				prop.Schedule:               "a",
+				prop.OperationCount:         "10",
+				"schedule.a.readproportion": "0.9",
+			},
+			phases: []phaseWant{
+				{name: "a", props: map[string]string{"readproportion": "0.9", prop.OperationCount: "10"}, newWorkload: true},
+			},
+		},
+		{
+			name:  "phase without properties",
+			props: map[string]string{prop.Schedule: "a", prop.OperationCount: "10"},
+			err:   true,
+		},
+		{
+			name:  "phase without end",
+			props: map[string]string{prop.Schedule: "a", "schedule.a.target": "10"},
+			err:   true,
+		},
+		{
+			name:  "invalid duration",
+			props: map[string]string{prop.Schedule: "a", "schedule.a.duration": "soon"},
+			err:   true,
+		},
+		{
+			name:  "sub-second duration",
+			props: map[string]string{prop.Schedule: "a", "schedule.a.duration": "500ms"},
+			err:   true,
+		},
+		{
+			name:  "fractional duration",
+			props: map[string]string{prop.Schedule: "a", "schedule.a.duration": "1.5s"},
+			err:   true,
+		},
+		{
+			name:  "negative duration",
+			props: map[string]string{prop.Schedule: "a", "schedule.a.duration": "-5"},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			phases, err := ParseSchedule(properties.LoadMap(tt.props))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("want an error, got %d phases", len(phases))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(phases) != len(tt.phases) {
+				t.Fatalf("want %d phases, got %d", len(tt.phases), len(phases))
+			}
+			for i, want := range tt.phases {
+				phase := phases[i]
+				if phase.Name != want.name || phase.NewWorkload != want.newWorkload {
+					t.Errorf("phase %d: want %s (new workload %v), got %s (new workload %v)",
+						i, want.name, want.newWorkload, phase.Name, phase.NewWorkload)
+				}
+				for key, value := range want.props {
+					if got, _ := phase.Props.Get(key); got != value {
+						t.Errorf("phase %s: want %s=%q, got %q", phase.Name, key, value, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRateCurve(t *testing.T) {
+	tests := []struct {
+		name   string
+		props  map[string]string
+		scale  float64
+		n      float64
+		offset time.Duration
+	}{
+		{
+			name:   "constant",
+			props:  map[string]string{prop.Target: "100"},
+			scale:  1,
+			n:      50,
+			offset: 500 * time.Millisecond,
+		},
+		{
+			name:   "scaled",
+			props:  map[string]string{prop.Target: "100"},
+			scale:  0.5,
+			n:      50,
+			offset: time.Second,
+		},
+		{
+			// 100 to 300 ops/s over 10s: 2000 ops by the end of the ramp
+			name:   "ramp",
+			props:  map[string]string{prop.Target: "100", prop.TargetEnd: "300", prop.MaxExecutiontime: "10"},
+			scale:  1,
+			n:      2000,
+			offset: 10 * time.Second,
+		},
+		{
+			// 100 ops/s + 20 ops/s^2: 100*5 + 10*25 = 750 ops in 5s
+			name:   "within ramp",
+			props:  map[string]string{prop.Target: "100", prop.TargetEnd: "300", prop.MaxExecutiontime: "10"},
+			scale:  1,
+			n:      750,
+			offset: 5 * time.Second,
+		},
+		{
+			name:   "ramp without duration",
+			props:  map[string]string{prop.Target: "100", prop.TargetEnd: "300"},
+			scale:  1,
+			n:      100,
+			offset: time.Second,
+		},
+		{
+			name:   "ramp from zero",
+			props:  map[string]string{prop.Target: "0", prop.TargetEnd: "200", prop.MaxExecutiontime: "10"},
+			scale:  1,
+			n:      1000,
+			offset: 10 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newRateCurve(properties.LoadMap(tt.props), tt.scale)
+			if c == nil {
+				t.Fatal("want a rate curve")
+			}
+			if d := c.offset(tt.n) - tt.offset; d < -time.Millisecond || d > time.Millisecond {
+				t.Errorf("want offset %s for %v ops, got %s", tt.offset, tt.n, c.offset(tt.n))
+			}
+		})
+	}
+
+	if c := newRateCurve(properties.LoadMap(map[string]string{prop.OperationCount: "10"}), 1); c != nil {
+		t.Errorf("want no rate curve without a target, got %+v", c)
+	}
+}
+
+// testDB reads every key.
+type testDB struct{}
+
+func (testDB) Close() error { return nil }
+func (testDB) InitThread(ctx context.Context, threadID int, threadCount int) context.Context {
+	return ctx
+}
+func (testDB) CleanupThread(ctx context.Context) {}
+func (testDB) Read(ctx context.Context, table string, key string, fields []string) (map[string][]byte, error) {
+	return map[string][]byte{}, nil
+}
+func (testDB) Scan(ctx context.Context, table string, startKey string, count int, fields []string) ([]map[string][]byte, error) {
+	return nil, nil
+}
+func (testDB) Update(ctx context.Context, table string, key string, values map[string][]byte) error {
+	return nil
+}
+func (testDB) Insert(ctx context.Context, table string, key string, values map[string][]byte) error {
+	return nil
+}
+func (testDB) Delete(ctx context.Context, table string, key string) error { return nil }
+
+// readWorkload reads user1 in every transaction.
+type readWorkload struct{}
+
+func (readWorkload) Close() error { return nil }
+func (readWorkload) InitThread(ctx context.Context, threadID int, threadCount int) context.Context {
+	return ctx
+}
+func (readWorkload) CleanupThread(ctx context.Context)                            {}
+func (readWorkload) Load(ctx context.Context, db ycsb.DB, totalCount int64) error { return nil }
+func (readWorkload) DoInsert(ctx context.Context, db ycsb.DB) error               { return nil }
+func (readWorkload) DoBatchInsert(ctx context.Context, batchSize int, db ycsb.DB) error {
+	return nil
+}
+func (readWorkload) DoTransaction(ctx context.Context, db ycsb.DB) error {
+	_, err := db.Read(ctx, "usertable", "user1", nil)
+	return err
+}
+func (readWorkload) DoBatchTransaction(ctx context.Context, batchSize int, db ycsb.DB) error {
+	return nil
+}
+
+// TestRunScheduleRawOutput runs a schedule with the raw latencies written to
+// a zstd compressed file, which must be complete once the run is closed.
+func TestRunScheduleRawOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "raw.csv.zst")
+	p := properties.LoadMap(map[string]string{
+		prop.Schedule:                 "a,b",
+		prop.DoTransactions:           "true",
+		prop.MeasurementType:          "raw",
+		prop.MeasurementRawOutputFile: path,
+		"schedule.a.operationcount":   "10",
+		"schedule.b.operationcount":   "20",
+	})
+	measurement.InitMeasure(p)
+	phases, err := ParseSchedule(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RunSchedule(context.Background(), phases, readWorkload{}, DbWrapper{DB: testDB{}}); err != nil {
+		t.Fatal(err)
+	}
+	measurement.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := zstd.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reads := strings.Count(string(data), "\nREAD,"); reads != 30 {
+		t.Fatalf("want 30 raw READ latencies, got %d", reads)
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package measurement
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/go-ycsb/pkg/util"
+)
+
+// compareMetrics are the metrics compared by CompareResults.
+var compareMetrics = []struct {
+	name string
+	// higherIsBetter is set for throughput, unset for latencies and errors
+	higherIsBetter bool
+	value          func(r *OperationResult) float64
+}{
+	{"ops", true, func(r *OperationResult) float64 { return r.OPS }},
+	{"avg", false, func(r *OperationResult) float64 { return float64(r.Avg) }},
+	{"p50", false, func(r *OperationResult) float64 { return float64(r.P50) }},
+	{"p90", false, func(r *OperationResult) float64 { return float64(r.P90) }},
+	{"p95", false, func(r *OperationResult) float64 { return float64(r.P95) }},
+	{"p99", false, func(r *OperationResult) float64 { return float64(r.P99) }},
+	{"p999", false, func(r *OperationResult) float64 { return float64(r.P999) }},
+	{"p9999", false, func(r *OperationResult) float64 { return float64(r.P9999) }},
+	{"errors", false, func(r *OperationResult) float64 { return float64(r.Errors) }},
+}
+
+// CompareMetrics returns the names of the metrics compared by CompareResults.
+func CompareMetrics() []string {
+	names := make([]string, len(compareMetrics))
+	for i, metric := range compareMetrics {
+		names[i] = metric.name
+	}
+	return names
+}
+
+// ResultSet is the results of the repetitions of one benchmark.
+type ResultSet struct {
+	Name    string
+	Results []*Result
+}
+
+// LoadResultSet reads a result file, or every result file in a directory and
+// its subdirectories as repetitions of the same benchmark. Other JSON files
+// in a directory are skipped.
+func LoadResultSet(path string) (*ResultSet, error) {
+	s := &ResultSet{Name: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		r, err := ReadResult(path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s failed: %w", path, err)
+		}
+		s.Results = append(s.Results, r)
+		return s, nil
+	}
+
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".json") {
+			return err
+		}
+		if r, err := ReadResult(p); err == nil && r.Version > 0 {
+			s.Results = append(s.Results, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Results) == 0 {
+		return nil, fmt.Errorf("no result files found in %s", path)
+	}
+	return s, nil
+}
+
+// CompareOptions selects how CompareResults compares result sets.
+type CompareOptions struct {
+	// Threshold is the change in percent beyond which a worse gated metric
+	// is a regression.
+	Threshold float64
+	// Gate are the metrics which can regress, such as "ops" and "p99".
+	Gate []string
+	// Confidence is the level of the bootstrap confidence intervals, and
+	// Resamples the number of bootstrap resamples.
+	Confidence float64
+	Resamples  int
+}
+
+// Comparison compares result sets with a baseline.
+type Comparison struct {
+	Baseline   string                `json:"baseline"`
+	Candidates []CandidateComparison `json:"candidates"`
+}
+
+// CandidateComparison compares one result set with the baseline.
+type CandidateComparison struct {
+	Name    string             `json:"name"`
+	Metrics []MetricComparison `json:"metrics"`
+	// Missing are the operations of the baseline the candidate doesn't
+	// have, as phase/operation or operation
+	Missing     []string `json:"missing,omitempty"`
+	Regressions int      `json:"regressions"`
+}
+
+// MetricComparison compares one metric of one operation, averaged over the
+// repetitions of each result set.
+type MetricComparison struct {
+	Phase     string  `json:"phase,omitempty"`
+	Operation string  `json:"operation"`
+	Metric    string  `json:"metric"`
+	Baseline  float64 `json:"baseline"`
+	Candidate float64 `json:"candidate"`
+	// Delta is the change from the baseline in percent
+	Delta float64 `json:"delta"`
+	// CI is the bootstrap confidence interval of Delta, only if both result
+	// sets have several repetitions
+	CI []float64 `json:"ci,omitempty"`
+	// Significant is set if CI doesn't include 0
+	Significant bool `json:"significant"`
+	Regression  bool `json:"regression"`
+}
+
+// operationKey identifies an operation of a phase.
+type operationKey struct {
+	phase string
+	op    string
+}
+
+func (k operationKey) String() string {
+	if k.phase == "" {
+		return k.op
+	}
+	return k.phase + "/" + k.op
+}
+
+// samples returns the results of every operation of every repetition of s.
+func (s *ResultSet) samples() map[operationKey][]*OperationResult {
+	samples := make(map[operationKey][]*OperationResult)
+	for _, r := range s.Results {
+		for _, phase := range r.Phases {
+			for op, o := range phase.Operations {
+				k := operationKey{phase: phase.Name, op: op}
+				samples[k] = append(samples[k], o)
+			}
+		}
+	}
+	return samples
+}
+
+// CompareResults compares every candidate with the baseline, operation by
+// operation. An operation of the baseline which a candidate doesn't have, such
+// as after a crash, is a regression if any metric is gated. Operations only a
+// candidate has are left out.
+func CompareResults(baseline *ResultSet, candidates []*ResultSet, opts CompareOptions) *Comparison {
+	gated := make(map[string]bool, len(opts.Gate))
+	for _, metric := range opts.Gate {
+		gated[metric] = true
+	}
+	// A fixed seed makes comparisons reproducible
+	rnd := rand.New(rand.NewSource(1))
+
+	c := &Comparison{Baseline: baseline.Name}
+	baseSamples := baseline.samples()
+	for _, candidate := range candidates {
+		cc := CandidateComparison{Name: candidate.Name}
+		candSamples := candidate.samples()
+
+		keys := make([]operationKey, 0, len(baseSamples))
+		for k := range baseSamples {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].phase != keys[j].phase {
+				return keys[i].phase < keys[j].phase
+			}
+			return keys[i].op < keys[j].op
+		})
+
+		present := keys[:0]
+		for _, k := range keys {
+			if _, ok := candSamples[k]; ok {
+				present = append(present, k)
+				continue
+			}
+			cc.Missing = append(cc.Missing, k.String())
+			if len(gated) > 0 {
+				cc.Regressions++
+			}
+		}
+		keys = present
+
+		for _, k := range keys {
+			for _, metric := range compareMetrics {
+				base := metricValues(baseSamples[k], metric.value)
+				cand := metricValues(candSamples[k], metric.value)
+				m := MetricComparison{
+					Phase:     k.phase,
+					Operation: k.op,
+					Metric:    metric.name,
+					Baseline:  mean(base),
+					Candidate: mean(cand),
+				}
+				m.Delta = relativeDelta(m.Baseline, m.Candidate)
+				if len(base) > 1 && len(cand) > 1 {
+					low, high := bootstrapDelta(rnd, base, cand, opts.Confidence, opts.Resamples)
+					m.CI = []float64{low, high}
+					m.Significant = low > 0 || high < 0
+				}
+
+				worse := m.Delta
+				if metric.higherIsBetter {
+					worse = -worse
+				}
+				if gated[metric.name] && worse > opts.Threshold {
+					// With repetitions, only a significant change is a regression
+					m.Regression = m.CI == nil || m.Significant
+				}
+				if m.Regression {
+					cc.Regressions++
+				}
+				cc.Metrics = append(cc.Metrics, m)
+			}
+		}
+		c.Candidates = append(c.Candidates, cc)
+	}
+	return c
+}
+
+func metricValues(results []*OperationResult, value func(r *OperationResult) float64) []float64 {
+	values := make([]float64, len(results))
+	for i, r := range results {
+		values[i] = value(r)
+	}
+	return values
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// relativeDelta returns the change from base to cand in percent of base, or
+// of 1 (us or ops/s) if base is smaller, so that a change from 0 is finite.
+func relativeDelta(base float64, cand float64) float64 {
+	return (cand - base) / math.Max(base, 1) * 100
+}
+
+// bootstrapDelta returns the confidence interval of the relative change of
+// the mean from base to cand, resampling the repetitions of each.
+func bootstrapDelta(rnd *rand.Rand, base []float64, cand []float64, confidence float64, resamples int) (float64, float64) {
+	resample := func(values []float64) float64 {
+		var sum float64
+		for range values {
+			sum += values[rnd.Intn(len(values))]
+		}
+		return sum / float64(len(values))
+	}
+
+	deltas := make([]float64, resamples)
+	for i := range deltas {
+		deltas[i] = relativeDelta(resample(base), resample(cand))
+	}
+	sort.Float64s(deltas)
+
+	alpha := (1 - confidence) / 2
+	low := int(alpha * float64(resamples))
+	high := int(math.Ceil((1-alpha)*float64(resamples))) - 1
+	if high >= resamples {
+		high = resamples - 1
+	}
+	return deltas[low], deltas[high]
+}
+
+var compareHeader = []string{"Operation", "Metric", "Baseline", "Candidate", "Delta(%)", "CI(%)", ""}
+
+// WriteText writes a table of every candidate's metrics to w.
+func (c *Comparison) WriteText(w io.Writer) {
+	for _, cc := range c.Candidates {
+		fmt.Fprintf(w, "%s vs %s\n", cc.Name, c.Baseline)
+		lines := make([][]string, 0, len(cc.Metrics))
+		for _, m := range cc.Metrics {
+			op := operationKey{phase: m.Phase, op: m.Operation}.String()
+			ci := "-"
+			if m.CI != nil {
+				ci = fmt.Sprintf("[%+.1f, %+.1f]", m.CI[0], m.CI[1])
+			}
+			flag := ""
+			if m.Regression {
+				flag = "REGRESSION"
+			} else if m.Significant {
+				flag = "significant"
+			}
+			lines = append(lines, []string{op, m.Metric, util.FloatToOneString(m.Baseline),
+				util.FloatToOneString(m.Candidate), fmt.Sprintf("%+.1f", m.Delta), ci, flag})
+		}
+		util.RenderTable(w, compareHeader, lines)
+		for _, op := range cc.Missing {
+			fmt.Fprintf(w, "%s missing in %s\n", op, cc.Name)
+		}
+		fmt.Fprintf(w, "%d regressions\n\n", cc.Regressions)
+	}
+}
+
+// Regressions returns the number of regressions of every candidate.
+func (c *Comparison) Regressions() int {
+	var n int
+	for _, cc := range c.Candidates {
+		n += cc.Regressions
+	}
+	return n
+}
@@ -1,52 +1,187 @@
 package measurement
 
 import (
+	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/klauspost/compress/zstd"
 )
 
+// rawBatchSize is the number of entries a thread buffers before they are
+// written.
+const rawBatchSize = 4096
+
 type csventry struct {
+	op string
 	// start time of the operation in us from unix epoch
 	startUs int64
 	// latency of the operation in us
 	latencyUs int64
+	// thread is the thread id, -1 for operations measured without a thread
+	thread int
+	key    string
+	err    bool
 }
 
+// rawBatch is a batch of entries to write, or a request to flush the entries
+// written so far if flushed is set.
+type rawBatch struct {
+	entries []csventry
+	flushed chan error
+}
+
+// csvs streams every measured operation to the output file, or to stdout,
+// from a background goroutine.
 type csvs struct {
-	opCsv map[string][]csventry
+	batches chan rawBatch
+	done    chan error
+	closed  bool
 }
 
 func (c *csvs) GenerateExtendedOutputs() {
 }
 
-func InitCSV() *csvs {
-	return &csvs{
-		opCsv: make(map[string][]csventry),
+// InitCSV starts writing entries to path, zstd compressed if it ends in .zst
+// or .zstd. An empty path writes to stdout.
+func InitCSV(path string) *csvs {
+	var out io.Writer = os.Stdout
+	var file *os.File
+	var encoder *zstd.Encoder
+	if path != "" {
+		var err error
+		if file, err = os.Create(path); err != nil {
+			panic("failed to create output file: " + err.Error())
+		}
+		out = file
+		if strings.HasSuffix(path, ".zst") || strings.HasSuffix(path, ".zstd") {
+			if encoder, err = zstd.NewWriter(file); err != nil {
+				panic("failed to create zstd encoder: " + err.Error())
+			}
+			out = encoder
+		}
+	}
+
+	c := &csvs{
+		batches: make(chan rawBatch, 16),
+		done:    make(chan error, 1),
+	}
+	go func() {
+		err := c.run(bufio.NewWriterSize(out, 256*1024), encoder)
+		if encoder != nil {
+			if cerr := encoder.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if file != nil {
+			if cerr := file.Close(); err == nil {
+				err = cerr
+			}
+		}
+		c.done <- err
+	}()
+	return c
+}
+
+// run writes batches until the channel is closed. Entries written after an
+// error are dropped.
+func (c *csvs) run(w *bufio.Writer, encoder *zstd.Encoder) error {
+	_, err := fmt.Fprintln(w, "operation,timestamp_us,latency_us,thread,key,error")
+	var b []byte
+	for batch := range c.batches {
+		if batch.flushed != nil {
+			if err == nil {
+				err = w.Flush()
+			}
+			if err == nil && encoder != nil {
+				err = encoder.Flush()
+			}
+			batch.flushed <- err
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		for _, e := range batch.entries {
+			b = b[:0]
+			b = append(b, e.op...)
+			b = append(b, ',')
+			b = strconv.AppendInt(b, e.startUs, 10)
+			b = append(b, ',')
+			b = strconv.AppendInt(b, e.latencyUs, 10)
+			b = append(b, ',')
+			b = strconv.AppendInt(b, int64(e.thread), 10)
+			b = append(b, ',')
+			// Keys written by go-ycsb never need CSV quoting
+			b = append(b, e.key...)
+			b = append(b, ',')
+			b = strconv.AppendBool(b, e.err)
+			b = append(b, '\n')
+			if _, err = w.Write(b); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	return err
+}
+
+// write queues entries, blocking while the writer is behind.
+func (c *csvs) write(entries []csventry) {
+	if len(entries) > 0 {
+		c.batches <- rawBatch{entries: entries}
+	}
+}
+
+// flush waits until the queued entries are written to the output.
+func (c *csvs) flush() error {
+	flushed := make(chan error, 1)
+	c.batches <- rawBatch{flushed: flushed}
+	return <-flushed
+}
+
+// close writes the queued entries and closes the output.
+func (c *csvs) close() error {
+	if c.closed {
+		return nil
 	}
+	c.closed = true
+	close(c.batches)
+	return <-c.done
 }
 
 func (c *csvs) Measure(op string, start time.Time, lan time.Duration) {
-	c.opCsv[op] = append(c.opCsv[op], csventry{
+	c.write([]csventry{newCSVEntry(op, start, lan, -1, "")})
+}
+
+// newCSVEntry returns the entry of an operation. Failed operations, measured
+// as <op>_ERROR, are written as op with the error flag.
+func newCSVEntry(op string, start time.Time, lan time.Duration, thread int, key string) csventry {
+	name := strings.TrimSuffix(op, errorSuffix)
+	return csventry{
+		op:        name,
 		startUs:   start.UnixMicro(),
 		latencyUs: lan.Microseconds(),
-	})
+		thread:    thread,
+		key:       key,
+		err:       name != op,
+	}
+}
+
+func (c *csvs) mergeShard(op string, o *shardOp) {
+	// Entries are written as they are measured
 }
 
+// Output waits until every entry measured so far is written. The entries
+// are written to their own output rather than to w.
 func (c *csvs) Output(w io.Writer) error {
-	_, err := fmt.Fprintln(w, "operation,timestamp_us,latency_us")
-	if err != nil {
-		return err
-	}
-	for op, entries := range c.opCsv {
-		for _, entry := range entries {
-			_, err := fmt.Fprintf(w, "%s,%d,%d\n", op, entry.startUs, entry.latencyUs)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	return nil
+	return c.flush()
 }
 
 func (c *csvs) Summary() {
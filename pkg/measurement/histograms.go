@@ -51,6 +51,19 @@ func (h *histograms) Measure(op string, start time.Time, lan time.Duration) {
 	opM.Measure(lan)
 }
 
+func (h *histograms) mergeShard(op string, o *shardOp) {
+	opM, ok := h.histograms[op]
+	if !ok {
+		opM = newHistogram()
+		opM.startTime = o.created
+		h.histograms[op] = opM
+	} else if o.created.Before(opM.startTime) {
+		opM.startTime = o.created
+	}
+
+	opM.hist.Merge(o.hist)
+}
+
 func (h *histograms) summary() map[string][]string {
 	summaries := make(map[string][]string, len(h.histograms))
 	for op, opM := range h.histograms {
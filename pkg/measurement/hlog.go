@@ -0,0 +1,196 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package measurement
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// hlogMaxValueRatio scales the interval max, recorded in us, to ms as in the
+// logs of other HdrHistogram tools.
+const hlogMaxValueRatio = 1000.0
+
+// hlogWriter writes interval histograms in the HdrHistogram interval log
+// format, with timestamps relative to the start of the log.
+type hlogWriter struct {
+	w    *bufio.Writer
+	base time.Time
+}
+
+func newHlogWriter(w io.Writer, base time.Time) *hlogWriter {
+	l := &hlogWriter{w: bufio.NewWriter(w), base: base}
+	baseSec := float64(base.UnixMilli()) / 1000
+	fmt.Fprintf(l.w, "#[Histogram log format version %s]\n", hdrhistogram.HISTOGRAM_LOG_FORMAT_VERSION)
+	fmt.Fprintf(l.w, "#[StartTime: %.3f (seconds since epoch), %s]\n", baseSec, base.Format(time.RFC3339))
+	fmt.Fprintf(l.w, "#[BaseTime: %.3f (seconds since epoch)]\n", baseSec)
+	fmt.Fprintln(l.w, `"StartTimestamp","Interval_Length","Interval_Max","Interval_Compressed_Histogram"`)
+	return l
+}
+
+// createHlog creates an interval log file whose timestamps start at base.
+func createHlog(path string, base time.Time) (*hlogWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return newHlogWriter(f, base), nil
+}
+
+// write appends the histogram of the interval from start to end.
+func (l *hlogWriter) write(h *hdrhistogram.Histogram, start time.Time, end time.Time) error {
+	payload, err := h.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+	if err != nil {
+		return err
+	}
+	if h.Tag() != "" {
+		fmt.Fprintf(l.w, "Tag=%s,", h.Tag())
+	}
+	_, err = fmt.Fprintf(l.w, "%.3f,%.3f,%.3f,%s\n", start.Sub(l.base).Seconds(), end.Sub(start).Seconds(),
+		float64(h.Max())/hlogMaxValueRatio, payload)
+	return err
+}
+
+func (l *hlogWriter) flush() error {
+	return l.w.Flush()
+}
+
+// HlogMergeOptions selects how MergeHistogramLogs combines interval logs.
+type HlogMergeOptions struct {
+	// Interval is the length of the merged intervals. Input intervals are
+	// merged into the interval their start falls in.
+	Interval time.Duration
+	// Start and End select the time window [Start, End) relative to the
+	// earliest interval. End <= 0 means until the end of the logs.
+	Start, End time.Duration
+}
+
+// HlogMergeResult counts the intervals read and written by
+// MergeHistogramLogs, and has the totals of every tag ("" for untagged
+// histograms).
+type HlogMergeResult struct {
+	Read    int
+	Written int
+	Totals  map[string]*hdrhistogram.Histogram
+}
+
+type hlogInterval struct {
+	start time.Time
+	hist  *hdrhistogram.Histogram
+}
+
+// readHlog reads every interval histogram of a log.
+func readHlog(path string) ([]hlogInterval, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var intervals []hlogInterval
+	r := hdrhistogram.NewHistogramLogReader(f)
+	for {
+		h, err := r.NextIntervalHistogram()
+		if err != nil {
+			return nil, fmt.Errorf("read %s failed: %w", path, err)
+		}
+		if h == nil {
+			return intervals, nil
+		}
+		intervals = append(intervals, hlogInterval{start: time.UnixMilli(h.StartTimeMs()), hist: h})
+	}
+}
+
+// MergeHistogramLogs merges the interval logs of several clients, for example
+// of one operation measured by each, into one log written to w.
+func MergeHistogramLogs(paths []string, w io.Writer, opts HlogMergeOptions) (HlogMergeResult, error) {
+	res := HlogMergeResult{Totals: make(map[string]*hdrhistogram.Histogram)}
+	if opts.Interval <= 0 {
+		return res, fmt.Errorf("invalid merge interval %s", opts.Interval)
+	}
+
+	var intervals []hlogInterval
+	for _, path := range paths {
+		logIntervals, err := readHlog(path)
+		if err != nil {
+			return res, err
+		}
+		intervals = append(intervals, logIntervals...)
+	}
+	res.Read = len(intervals)
+	if len(intervals) == 0 {
+		return res, fmt.Errorf("no interval histograms found")
+	}
+
+	base := intervals[0].start
+	for _, i := range intervals {
+		if i.start.Before(base) {
+			base = i.start
+		}
+	}
+
+	type key struct {
+		window int64
+		tag    string
+	}
+	merged := make(map[key]*hdrhistogram.Histogram)
+	for _, i := range intervals {
+		offset := i.start.Sub(base)
+		if offset < opts.Start || (opts.End > 0 && offset >= opts.End) {
+			continue
+		}
+		k := key{window: int64(offset / opts.Interval), tag: i.hist.Tag()}
+		h, ok := merged[k]
+		if !ok {
+			h = hdrhistogram.New(1, 24*60*60*1000*1000, 3)
+			h.SetTag(k.tag)
+			merged[k] = h
+		}
+		h.Merge(i.hist)
+
+		total, ok := res.Totals[k.tag]
+		if !ok {
+			total = hdrhistogram.New(1, 24*60*60*1000*1000, 3)
+			res.Totals[k.tag] = total
+		}
+		total.Merge(i.hist)
+	}
+
+	keys := make([]key, 0, len(merged))
+	for k := range merged {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].window != keys[j].window {
+			return keys[i].window < keys[j].window
+		}
+		return keys[i].tag < keys[j].tag
+	})
+
+	out := newHlogWriter(w, base)
+	for _, k := range keys {
+		start := base.Add(time.Duration(k.window) * opts.Interval)
+		if err := out.write(merged[k], start, start.Add(opts.Interval)); err != nil {
+			return res, err
+		}
+		res.Written++
+	}
+	return res, out.flush()
+}
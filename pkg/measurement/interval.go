@@ -0,0 +1,273 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package measurement
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+	"github.com/pingcap/go-ycsb/pkg/util"
+)
+
+// Time-series file formats
+const (
+	timeSeriesCSV       = "csv"
+	timeSeriesJSONLines = "jsonl"
+)
+
+var timeSeriesHeader = []string{"time", "elapsed", "operation", "count", "ops", "avg_us", "min_us", "max_us",
+	"p50_us", "p90_us", "p95_us", "p99_us", "p999_us", "p9999_us"}
+
+// intervals keeps the latencies measured since the last report, so that
+// every report covers one interval instead of the whole run.
+type intervals struct {
+	print bool
+
+	// seriesStart is the start of the first interval, start the start of the
+	// current one
+	seriesStart time.Time
+	start       time.Time
+	histograms  map[string]*histogram
+
+	w     *bufio.Writer
+	jsonl bool
+
+	// hlogPath is the path prefix of the interval logs of every operation,
+	// empty if they are not exported
+	hlogPath string
+	hlogs    map[string]*hlogWriter
+
+	// results are the intervals reported since the last takeResults, kept
+	// for the result file if keepResults is set
+	keepResults bool
+	results     []IntervalResult
+
+	// handler is called with the latencies of every reported interval
+	handler func(s *Snapshot)
+}
+
+func newIntervals(p *properties.Properties) *intervals {
+	i := &intervals{
+		print:       p.GetBool(prop.MeasurementIntervalPrint, prop.MeasurementIntervalPrintDefault),
+		start:       time.Now(),
+		histograms:  make(map[string]*histogram, 16),
+		keepResults: p.GetString(prop.MeasurementResultFile, "") != "",
+	}
+	if p.GetBool(prop.MeasurementHistogramHlogExport, prop.MeasurementHistogramHlogExportDefault) {
+		i.hlogPath = p.GetString(prop.MeasurementHistogramHlogExportFilepath, prop.MeasurementHistogramHlogExportFilepathDefault)
+		i.hlogs = make(map[string]*hlogWriter)
+	}
+
+	path := p.GetString(prop.MeasurementTimeSeriesFile, "")
+	if path == "" {
+		return i
+	}
+	format := p.GetString(prop.MeasurementTimeSeriesFormat, "")
+	if format == "" {
+		format = timeSeriesCSV
+		if strings.HasSuffix(path, ".jsonl") || strings.HasSuffix(path, ".json") {
+			format = timeSeriesJSONLines
+		}
+	}
+	if format != timeSeriesCSV && format != timeSeriesJSONLines {
+		panic("unsupported time series format: " + format)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		panic("failed to create time series file: " + err.Error())
+	}
+	i.w = bufio.NewWriter(f)
+	i.jsonl = format == timeSeriesJSONLines
+	if !i.jsonl {
+		fmt.Fprintln(i.w, strings.Join(timeSeriesHeader, ","))
+	}
+	return i
+}
+
+// merge adds latencies of op to the current interval.
+func (i *intervals) merge(op string, from *hdrhistogram.Histogram) {
+	opM, ok := i.histograms[op]
+	if !ok {
+		opM = newHistogram()
+		i.histograms[op] = opM
+	}
+	opM.hist.Merge(from)
+}
+
+// reset discards the latencies of the current interval and starts a new one.
+func (i *intervals) reset(now time.Time) {
+	if i.seriesStart.IsZero() {
+		i.seriesStart = now
+	}
+	i.start = now
+	for _, opM := range i.histograms {
+		opM.hist.Reset()
+	}
+}
+
+// IntervalResult is one operation of one interval, as written to the
+// time-series file in the jsonl format and to the result file.
+type IntervalResult struct {
+	Time      float64 `json:"time"`
+	Elapsed   float64 `json:"elapsed"`
+	Operation string  `json:"operation"`
+	Count     int64   `json:"count"`
+	OPS       float64 `json:"ops"`
+	Avg       int64   `json:"avg_us"`
+	Min       int64   `json:"min_us"`
+	Max       int64   `json:"max_us"`
+	P50       int64   `json:"p50_us"`
+	P90       int64   `json:"p90_us"`
+	P95       int64   `json:"p95_us"`
+	P99       int64   `json:"p99_us"`
+	P999      int64   `json:"p999_us"`
+	P9999     int64   `json:"p9999_us"`
+}
+
+// report prints the current interval, ending at now, and appends it to the
+// time-series file. Operations without latencies in the interval are left
+// out.
+func (i *intervals) report(now time.Time) {
+	ops := make([]string, 0, len(i.histograms))
+	for op, opM := range i.histograms {
+		if opM.hist.TotalCount() > 0 {
+			ops = append(ops, op)
+		}
+	}
+	sort.Strings(ops)
+
+	elapsed := now.Sub(i.seriesStart).Seconds()
+	length := now.Sub(i.start).Seconds()
+	if length <= 0 {
+		return
+	}
+	if i.print {
+		fmt.Printf("[%.1fs] interval of %.1fs\n", elapsed, length)
+	}
+
+	lines := make([][]string, 0, len(ops))
+	for _, op := range ops {
+		h := i.histograms[op].hist
+		r := IntervalResult{
+			Time:      float64(now.UnixMilli()) / 1000,
+			Elapsed:   elapsed,
+			Operation: op,
+			Count:     h.TotalCount(),
+			OPS:       float64(h.TotalCount()) / length,
+			Avg:       int64(h.Mean()),
+			Min:       h.Min(),
+			Max:       h.Max(),
+			P50:       h.ValueAtPercentile(50),
+			P90:       h.ValueAtPercentile(90),
+			P95:       h.ValueAtPercentile(95),
+			P99:       h.ValueAtPercentile(99),
+			P999:      h.ValueAtPercentile(99.9),
+			P9999:     h.ValueAtPercentile(99.99),
+		}
+		if i.print {
+			lines = append(lines, []string{op, util.FloatToOneString(length), util.IntToString(r.Count),
+				util.FloatToOneString(r.OPS), util.IntToString(r.Avg), util.IntToString(r.Min), util.IntToString(r.Max),
+				util.IntToString(r.P50), util.IntToString(r.P90), util.IntToString(r.P95), util.IntToString(r.P99),
+				util.IntToString(r.P999), util.IntToString(r.P9999)})
+		}
+		if i.w != nil {
+			i.write(&r)
+		}
+		if i.keepResults {
+			i.results = append(i.results, r)
+		}
+	}
+
+	if i.print {
+		util.RenderString(os.Stdout, "%-6s - %s\n", header, lines)
+	}
+	if i.w != nil {
+		if err := i.w.Flush(); err != nil {
+			panic("failed to write time series: " + err.Error())
+		}
+	}
+	if i.hlogs != nil {
+		i.writeHlogs(ops, now)
+	}
+	if i.handler != nil {
+		hists := make(map[string]*hdrhistogram.Histogram, len(ops))
+		for _, op := range ops {
+			hists[op] = i.histograms[op].hist
+		}
+		i.handler(newSnapshot(i.start, now, hists))
+	}
+}
+
+// writeHlogs appends the interval to the interval log of every operation.
+func (i *intervals) writeHlogs(ops []string, now time.Time) {
+	for _, op := range ops {
+		l, ok := i.hlogs[op]
+		if !ok {
+			path := fmt.Sprintf("%s%s.hlog", i.hlogPath, op)
+			var err error
+			if l, err = createHlog(path, i.seriesStart); err != nil {
+				panic("failed to create interval log: " + err.Error())
+			}
+			i.hlogs[op] = l
+		}
+		err := l.write(i.histograms[op].hist, i.start, now)
+		if err == nil {
+			err = l.flush()
+		}
+		if err != nil {
+			panic("failed to write interval log: " + err.Error())
+		}
+	}
+}
+
+// takeResults returns the intervals reported since the last call.
+func (i *intervals) takeResults() []IntervalResult {
+	results := i.results
+	i.results = nil
+	return results
+}
+
+func (i *intervals) write(r *IntervalResult) {
+	if i.jsonl {
+		data, err := json.Marshal(r)
+		if err != nil {
+			panic("failed to encode time series: " + err.Error())
+		}
+		i.w.Write(data)
+		i.w.WriteByte('\n')
+		return
+	}
+	fields := []string{
+		strconv.FormatFloat(r.Time, 'f', 3, 64),
+		strconv.FormatFloat(r.Elapsed, 'f', 3, 64),
+		r.Operation,
+		strconv.FormatInt(r.Count, 10),
+		strconv.FormatFloat(r.OPS, 'f', 1, 64),
+	}
+	for _, v := range []int64{r.Avg, r.Min, r.Max, r.P50, r.P90, r.P95, r.P99, r.P999, r.P9999} {
+		fields = append(fields, strconv.FormatInt(v, 10))
+	}
+	i.w.WriteString(strings.Join(fields, ","))
+	i.w.WriteByte('\n')
+}
@@ -15,7 +15,10 @@ package measurement
 
 import (
 	"bufio"
+	"context"
+	"fmt"
 	"os"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -27,25 +30,100 @@ import (
 
 var header = []string{"Operation", "Takes(s)", "Count", "OPS", "Avg(us)", "Min(us)", "Max(us)", "50th(us)", "90th(us)", "95th(us)", "99th(us)", "99.9th(us)", "99.99th(us)"}
 
+// measurer is a ycsb.Measurer which takes the latencies recorded by shards.
+type measurer interface {
+	ycsb.Measurer
+
+	// mergeShard adds the latencies of op recorded by a shard since the last
+	// merge.
+	mergeShard(op string, o *shardOp)
+}
+
 type measurement struct {
-	sync.RWMutex
+	// Mutex guards the fields below. The lock of a shard is taken after it.
+	sync.Mutex
 
 	p *properties.Properties
 
-	measurer ycsb.Measurer
+	measurer  measurer
+	intervals *intervals
+	// raw is the raw measurer which shards write every latency to, nil for
+	// other measurers. metrics is set if shards keep Prometheus metrics.
+	raw     *csvs
+	metrics bool
+	// totals has the latencies of the current phase for its result, the
+	// measurer itself unless the latencies are raw
+	totals *histograms
+
+	// phaseStart is when the current phase started to be measured, phases
+	// the results of the phases output so far
+	phaseStart time.Time
+	phases     []PhaseResult
+
+	// shards are taken in turn by the threads, nextShard counts them
+	shards    []*shard
+	nextShard uint32
+	// fallback records operations measured without a thread
+	fallback recorder
 }
 
-func (m *measurement) measure(op string, start time.Time, lan time.Duration) {
-	m.Lock()
-	m.measurer.Measure(op, start, lan)
-	m.Unlock()
+// merge moves the latencies recorded by s to the measurer and the current
+// interval.
+func (m *measurement) merge(s *shard) {
+	s.Lock()
+	for op, o := range s.ops {
+		if o.hist.TotalCount() == 0 {
+			continue
+		}
+		m.measurer.mergeShard(op, o)
+		if m.raw != nil {
+			m.totals.mergeShard(op, o)
+		}
+		m.intervals.merge(op, o.hist)
+		o.hist.Reset()
+	}
+	entries := s.entries
+	s.entries = nil
+	s.Unlock()
+
+	if m.raw != nil {
+		m.raw.write(entries)
+	}
 }
 
-func (m *measurement) output() {
-	m.RLock()
-	defer m.RUnlock()
+// mergeAll merges the latencies of every shard.
+func (m *measurement) mergeAll() {
+	for _, s := range m.shards {
+		m.merge(s)
+	}
+	m.merge(m.fallback.shard)
+}
+
+// output outputs the measurements of the phase name, "" for a whole run.
+func (m *measurement) output(name string) {
+	m.Lock()
+	defer m.Unlock()
+	m.mergeAll()
+	m.phases = append(m.phases, PhaseResult{
+		Name:       name,
+		Start:      m.phaseStart,
+		End:        time.Now(),
+		Operations: m.totals.results(),
+		Intervals:  m.intervals.takeResults(),
+	})
+	m.measurer.GenerateExtendedOutputs()
+	if m.raw != nil {
+		// Raw latencies are streamed to the output file during the run
+		if err := m.raw.Output(nil); err != nil {
+			panic("failed to write output: " + err.Error())
+		}
+		return
+	}
 
 	outFile := m.p.GetString(prop.MeasurementRawOutputFile, "")
+	if outFile != "" && name != "" {
+		outFile += "." + name
+	}
 	var w *bufio.Writer
 	if outFile == "" {
 		w = bufio.NewWriter(os.Stdout)
@@ -58,7 +136,7 @@ func (m *measurement) output() {
 		w = bufio.NewWriter(f)
 	}
 
-	err := globalMeasure.measurer.Output(w)
+	err := m.measurer.Output(w)
 	if err != nil {
 		panic("failed to write output: " + err.Error())
 	}
@@ -70,31 +148,125 @@ func (m *measurement) output() {
 }
 
 func (m *measurement) summary() {
-	m.RLock()
-	globalMeasure.measurer.Summary()
-	m.RUnlock()
+	m.Lock()
+	m.mergeAll()
+	m.measurer.Summary()
+	m.Unlock()
 }
 
-// InitMeasure initializes the global measurement.
-func InitMeasure(p *properties.Properties) {
-	globalMeasure = new(measurement)
-	globalMeasure.p = p
+// interval reports the latencies measured since the last interval if report
+// is set, and starts the next interval.
+func (m *measurement) interval(report bool) {
+	m.Lock()
+	defer m.Unlock()
+	m.mergeAll()
+	if m.raw != nil {
+		// Make the raw latencies so far visible in the output file
+		if err := m.raw.flush(); err != nil {
+			panic("failed to write output: " + err.Error())
+		}
+	}
+	now := time.Now()
+	if report {
+		m.intervals.report(now)
+	} else {
+		m.phaseStart = now
+	}
+	m.intervals.reset(now)
+}
+
+func newMeasurer(p *properties.Properties) measurer {
 	measurementType := p.GetString(prop.MeasurementType, prop.MeasurementTypeDefault)
 	switch measurementType {
 	case "histogram":
-		globalMeasure.measurer = InitHistograms(p)
+		return InitHistograms(p)
 	case "raw", "csv":
-		globalMeasure.measurer = InitCSV()
+		return InitCSV(p.GetString(prop.MeasurementRawOutputFile, ""))
 	default:
 		panic("unsupported measurement type: " + measurementType)
 	}
+}
+
+// newTotals returns the histograms of the results of m.
+func newTotals(p *properties.Properties, m measurer) *histograms {
+	if h, ok := m.(*histograms); ok {
+		return h
+	}
+	return InitHistograms(p)
+}
+
+// InitMeasure initializes the global measurement.
+func InitMeasure(p *properties.Properties) {
+	globalMeasure = new(measurement)
+	globalMeasure.p = p
+	globalMeasure.measurer = newMeasurer(p)
+	globalMeasure.intervals = newIntervals(p)
+	globalMeasure.raw, _ = globalMeasure.measurer.(*csvs)
+	globalMeasure.totals = newTotals(p, globalMeasure.measurer)
+	globalMeasure.phaseStart = time.Now()
+	globalMeasure.metrics = p.GetBool(prop.MeasurementPrometheus, prop.MeasurementPrometheusDefault)
+	globalMeasure.shards = make([]*shard, runtime.GOMAXPROCS(0))
+	for i := range globalMeasure.shards {
+		globalMeasure.shards[i] = newShard()
+	}
+	globalMeasure.fallback = recorder{shard: newShard(), thread: -1}
 	EnableWarmUp(p.GetInt64(prop.WarmUpTime, 0) > 0)
+
+	latency := p.GetString(prop.MeasurementLatency, prop.MeasurementLatencyDefault)
+	switch latency {
+	case latencyOp, latencyIntended, latencyBoth:
+		latencyMode = latency
+	default:
+		panic("unsupported measurement latency: " + latency)
+	}
 }
 
-// Output prints the complete measurements.
+// Output prints the complete measurements and closes the output.
 func Output() {
-	globalMeasure.measurer.GenerateExtendedOutputs()
-	globalMeasure.output()
+	globalMeasure.output("")
+	Close()
+}
+
+// Close writes the buffered raw latencies and closes measurement.output_file.
+// It must be called after the last OutputPhase of a schedule, as Output does.
+// Nothing can be measured after it.
+func Close() {
+	if globalMeasure.raw != nil {
+		if err := globalMeasure.raw.close(); err != nil {
+			panic("failed to close output: " + err.Error())
+		}
+	}
+}
+
+// OutputPhase prints the measurements of one phase of a schedule under its
+// name and starts new measurements for the next phase. With
+// measurement.output_file, each phase is written to the file name followed by
+// "." and the phase name, except for raw latencies which are all streamed to
+// measurement.output_file.
+func OutputPhase(name string) {
+	fmt.Printf("***************** phase %s *****************\n", name)
+	globalMeasure.output(name)
+
+	globalMeasure.Lock()
+	if globalMeasure.raw == nil {
+		globalMeasure.measurer = newMeasurer(globalMeasure.p)
+	}
+	globalMeasure.totals = newTotals(globalMeasure.p, globalMeasure.measurer)
+	globalMeasure.Unlock()
+}
+
+// StartInterval discards the latencies measured so far from the interval
+// reports, and starts the first interval of a run and the measurement of its
+// phase.
+func StartInterval() {
+	globalMeasure.interval(false)
+}
+
+// Interval reports the latencies measured since the last call or
+// StartInterval, printing them unless measurement.interval.print is false and
+// appending them to measurement.timeseries_file if set.
+func Interval() {
+	globalMeasure.interval(true)
 }
 
 // Summary prints the measurement summary.
@@ -116,12 +288,56 @@ func IsWarmUpFinished() bool {
 	return atomic.LoadInt32(&warmUp) == 0
 }
 
-// Measure measures the operation.
+// Measure measures the operation with the recorder shared by all threads. See
+// MeasureContext for the recorder of a thread.
 func Measure(op string, start time.Time, lan time.Duration) {
 	if IsWarmUpFinished() {
-		globalMeasure.measure(op, start, lan)
+		globalMeasure.fallback.shard.measure(op, "", -1, start, lan, globalMeasure.raw, globalMeasure.metrics)
 	}
 }
 
+// MeasureContext measures the operation on key with the recorder of the
+// thread of ctx (see InitThread).
+func MeasureContext(ctx context.Context, op string, key string, start time.Time, lan time.Duration) {
+	if IsWarmUpFinished() {
+		r := threadRecorder(ctx)
+		r.shard.measure(op, key, r.thread, start, lan, globalMeasure.raw, globalMeasure.metrics)
+	}
+}
+
+// Latency measurement modes of throttled operations
+const (
+	latencyOp       = "op"
+	latencyIntended = "intended"
+	latencyBoth     = "both"
+)
+
+type scheduledStartKey struct{}
+
+// WithScheduledStart returns a context that carries the time at which the
+// throttle or the trace replay scheduled the operations issued with it. Unless measurement.latency
+// is "op", their latency from this time is measured as INTENDED_<op> as well, so
+// that queueing delay behind a slow operation is not hidden (coordinated
+// omission). Returns ctx unchanged in "op" mode.
+func WithScheduledStart(ctx context.Context, start time.Time) context.Context {
+	if latencyMode == latencyOp {
+		return ctx
+	}
+	return context.WithValue(ctx, scheduledStartKey{}, start)
+}
+
+// ScheduledStart returns the scheduled start time carried by ctx, if any.
+func ScheduledStart(ctx context.Context) (time.Time, bool) {
+	start, ok := ctx.Value(scheduledStartKey{}).(time.Time)
+	return start, ok
+}
+
+// MeasureActualLatency reports whether operations with a scheduled start
+// should also be measured from their actual start.
+func MeasureActualLatency() bool {
+	return latencyMode != latencyIntended
+}
+
 var globalMeasure *measurement
+var latencyMode = latencyBoth
 var warmUp int32 // use as bool, 1 means in warmup progress, 0 means warmup finished.
@@ -0,0 +1,388 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package measurement
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/magiconair/properties"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// globalRecorder records latencies as measurement did before the shards:
+// every thread takes one lock to record a latency in the run and the interval
+// histograms.
+type globalRecorder struct {
+	sync.Mutex
+	run      map[string]*histogram
+	interval map[string]*histogram
+}
+
+func (g *globalRecorder) measure(op string, lan time.Duration) {
+	g.Lock()
+	for _, hists := range []map[string]*histogram{g.run, g.interval} {
+		h, ok := hists[op]
+		if !ok {
+			h = newHistogram()
+			hists[op] = h
+		}
+		h.Measure(lan)
+	}
+	g.Unlock()
+}
+
+// benchmarkMeasure measures b.N operations split over threads goroutines,
+// with the shards or with the global lock of the baseline. The recorders are
+// set up before the timed part, as they are at the start of a run.
+func benchmarkMeasure(b *testing.B, threads int, baseline bool) {
+	InitMeasure(properties.NewProperties())
+	g := &globalRecorder{run: make(map[string]*histogram), interval: make(map[string]*histogram)}
+	ops := []string{"READ", "UPDATE", "TOTAL"}
+	start := time.Now()
+
+	var ready, measured, done sync.WaitGroup
+	startCh, stopCh := make(chan struct{}), make(chan struct{})
+	ready.Add(threads)
+	measured.Add(threads)
+	done.Add(threads)
+	for t := 0; t < threads; t++ {
+		n := b.N / threads
+		if t < b.N%threads {
+			n++
+		}
+		go func(thread int, n int) {
+			defer done.Done()
+			ctx := InitThread(context.Background(), thread)
+			for _, op := range ops {
+				if baseline {
+					g.measure(op, 0)
+				} else {
+					MeasureContext(ctx, op, "", start, 0)
+				}
+			}
+			ready.Done()
+			<-startCh
+			for i := 0; i < n; i++ {
+				lan := time.Duration(i%5000) * time.Microsecond
+				if baseline {
+					g.measure(ops[i%len(ops)], lan)
+				} else {
+					MeasureContext(ctx, ops[i%len(ops)], "user1", start, lan)
+				}
+			}
+			measured.Done()
+			<-stopCh
+		}(t, n)
+	}
+	ready.Wait()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	close(startCh)
+	measured.Wait()
+	b.StopTimer()
+	close(stopCh)
+	done.Wait()
+}
+
+// BenchmarkMeasure compares the shards with the global lock of the baseline
+// as the number of threads grows. Run it with -cpu to vary GOMAXPROCS.
+func BenchmarkMeasure(b *testing.B) {
+	for _, threads := range []int{1, 16, 200, 1000} {
+		b.Run(fmt.Sprintf("shards/threads=%d", threads), func(b *testing.B) {
+			benchmarkMeasure(b, threads, false)
+		})
+		b.Run(fmt.Sprintf("baseline/threads=%d", threads), func(b *testing.B) {
+			benchmarkMeasure(b, threads, true)
+		})
+	}
+}
+
+func TestMeasureThreads(t *testing.T) {
+	InitMeasure(properties.NewProperties())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4*len(globalMeasure.shards); i++ {
+		wg.Add(1)
+		go func(thread int) {
+			defer wg.Done()
+			ctx := InitThread(context.Background(), thread)
+			for j := 0; j < 100; j++ {
+				MeasureContext(ctx, "READ", "user1", time.Now(), time.Millisecond)
+			}
+		}(i)
+	}
+	Measure("READ", time.Now(), time.Millisecond)
+	wg.Wait()
+
+	globalMeasure.Lock()
+	globalMeasure.mergeAll()
+	h := globalMeasure.measurer.(*histograms).histograms["READ"]
+	globalMeasure.Unlock()
+	if want := int64(400*len(globalMeasure.shards) + 1); h == nil || h.hist.TotalCount() != want {
+		t.Fatalf("expected %d READ latencies, got %v", want, h)
+	}
+	// The threads share the shards, which don't grow with them
+	if len(globalMeasure.shards) != runtime.GOMAXPROCS(0) {
+		t.Fatalf("expected a shard per GOMAXPROCS, got %d", len(globalMeasure.shards))
+	}
+}
+
+func TestRawOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "raw.csv")
+	p := properties.NewProperties()
+	p.Set("measurementtype", "raw")
+	p.Set("measurement.output_file", path)
+	InitMeasure(p)
+
+	ctx := InitThread(context.Background(), 3)
+	for i := 0; i < rawBatchSize+1; i++ {
+		MeasureContext(ctx, "READ", "user1", time.Now(), time.Millisecond)
+	}
+	MeasureContext(ctx, "UPDATE_ERROR", "user2", time.Now(), 2*time.Millisecond)
+	Output()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != rawBatchSize+3 {
+		t.Fatalf("expected %d lines, got %d", rawBatchSize+3, len(lines))
+	}
+	if lines[0] != "operation,timestamp_us,latency_us,thread,key,error" {
+		t.Fatalf("unexpected header %s", lines[0])
+	}
+	if fields := strings.Split(lines[1], ","); fields[0] != "READ" || fields[2] != "1000" || fields[3] != "3" || fields[4] != "user1" || fields[5] != "false" {
+		t.Fatalf("unexpected entry %s", lines[1])
+	}
+	if fields := strings.Split(lines[len(lines)-1], ","); fields[0] != "UPDATE" || fields[4] != "user2" || fields[5] != "true" {
+		t.Fatalf("unexpected error entry %s", lines[len(lines)-1])
+	}
+}
+
+func TestMergeHistogramLogs(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Unix(1700000000, 0)
+	var paths []string
+	for c := 0; c < 2; c++ {
+		path := filepath.Join(dir, fmt.Sprintf("client%d-READ.hlog", c))
+		l, err := createHlog(path, base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for s := 0; s < 4; s++ {
+			h := hdrhistogram.New(1, 24*60*60*1000*1000, 3)
+			h.RecordValue(int64(1000 * (s + 1)))
+			start := base.Add(time.Duration(s) * time.Second)
+			if err := l.write(h, start, start.Add(time.Second)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := l.flush(); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	var out strings.Builder
+	res, err := MergeHistogramLogs(paths, &out, HlogMergeOptions{Interval: 2 * time.Second, Start: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Read != 8 || res.Written != 2 {
+		t.Fatalf("expected 8 intervals merged into 2, got %d into %d", res.Read, res.Written)
+	}
+	if total := res.Totals[""]; total.TotalCount() != 6 || total.Min() != 2000 {
+		t.Fatalf("expected 6 latencies from 2ms, got %d from %d", total.TotalCount(), total.Min())
+	}
+
+	r := hdrhistogram.NewHistogramLogReader(strings.NewReader(out.String()))
+	var counts []int64
+	for {
+		h, err := r.NextIntervalHistogram()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h == nil {
+			break
+		}
+		counts = append(counts, h.TotalCount())
+	}
+	if len(counts) != 2 || counts[0] != 2 || counts[1] != 4 {
+		t.Fatalf("unexpected merged interval counts %v", counts)
+	}
+}
+
+func TestPhaseResults(t *testing.T) {
+	InitMeasure(properties.NewProperties())
+
+	ctx := InitThread(context.Background(), 0)
+	for i := 0; i < 10; i++ {
+		MeasureContext(ctx, "READ", "user1", time.Now(), time.Duration(i+1)*time.Millisecond)
+	}
+	MeasureContext(ctx, "READ_ERROR", "user2", time.Now(), time.Millisecond)
+	MeasureContext(ctx, "UPDATE_ERROR", "user3", time.Now(), time.Millisecond)
+	Output()
+
+	phases := Phases()
+	if len(phases) != 1 {
+		t.Fatalf("expected 1 phase, got %d", len(phases))
+	}
+	ops := phases[0].Operations
+	if r := ops["READ"]; r == nil || r.Count != 10 || r.Errors != 1 || r.Min != 1000 || r.Max < 10000 {
+		t.Fatalf("unexpected READ result %+v", r)
+	}
+	if r := ops["UPDATE"]; r == nil || r.Count != 0 || r.Errors != 1 {
+		t.Fatalf("unexpected UPDATE result %+v", r)
+	}
+	if _, ok := ops["READ_ERROR"]; ok {
+		t.Fatalf("expected errors to be counted in their operation")
+	}
+}
+
+func TestCompareResults(t *testing.T) {
+	newSet := func(name string, ops ...float64) *ResultSet {
+		s := &ResultSet{Name: name}
+		for _, o := range ops {
+			s.Results = append(s.Results, &Result{Phases: []PhaseResult{{
+				Operations: map[string]*OperationResult{"READ": {OPS: o, P99: 100}},
+			}}})
+		}
+		return s
+	}
+	opts := CompareOptions{Threshold: 5, Gate: []string{"ops"}, Confidence: 0.95, Resamples: 1000}
+	baseline := newSet("base", 1000, 1010, 990)
+
+	c := CompareResults(baseline, []*ResultSet{newSet("slow", 900, 910, 890), newSet("noisy", 500, 1500, 900), newSet("single", 940)}, opts)
+	for i, regressions := range []int{1, 0, 1} {
+		if got := c.Candidates[i].Regressions; got != regressions {
+			t.Fatalf("expected %d regressions of %s, got %d", regressions, c.Candidates[i].Name, got)
+		}
+	}
+
+	ops := c.Candidates[0].Metrics[0]
+	if ops.Metric != "ops" || ops.Delta > -9.9 || ops.Delta < -10.1 || len(ops.CI) != 2 || !ops.Significant {
+		t.Fatalf("unexpected ops comparison %+v", ops)
+	}
+	if c.Candidates[2].Metrics[0].CI != nil {
+		t.Fatalf("expected no confidence interval for a single repetition")
+	}
+
+	failing := newSet("failing", 1000)
+	failing.Results[0].Phases[0].Operations["READ"].Errors = 3
+	opts.Gate = []string{"errors"}
+	c = CompareResults(newSet("base", 1000), []*ResultSet{failing}, opts)
+	if errs := c.Candidates[0].Metrics[len(compareMetrics)-1]; errs.Metric != "errors" || errs.Delta != 300 || !errs.Regression {
+		t.Fatalf("unexpected errors comparison %+v", errs)
+	}
+
+	// A candidate which lost an operation of the baseline regresses
+	lost := newSet("lost", 1000)
+	lost.Results[0].Phases[0].Operations = map[string]*OperationResult{"UPDATE": {OPS: 1000}}
+	c = CompareResults(newSet("base", 1000), []*ResultSet{lost}, opts)
+	if cc := c.Candidates[0]; len(cc.Missing) != 1 || cc.Missing[0] != "READ" || cc.Regressions != 1 || len(cc.Metrics) != 0 {
+		t.Fatalf("expected the missing READ as a regression, got %+v", cc)
+	}
+}
+
+func TestMergeSnapshots(t *testing.T) {
+	merger := NewMerger(properties.NewProperties())
+	start := time.Now()
+	for agent := 0; agent < 3; agent++ {
+		h := hdrhistogram.New(1, 24*60*60*1000*1000, 3)
+		for i := 0; i < 100; i++ {
+			h.RecordValue(int64(1000 * (agent + 1)))
+		}
+		hists := map[string]*hdrhistogram.Histogram{"READ": h}
+		agentStart := start.Add(time.Duration(agent) * time.Millisecond)
+		if err := merger.Interval([]*Snapshot{newSnapshot(agentStart, agentStart.Add(time.Second), hists)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := merger.AddTotals(newSnapshot(agentStart, agentStart.Add(time.Second), hists)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	phase, err := merger.Output(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !phase.Start.Equal(start) {
+		t.Fatalf("expected the run to start with the first agent")
+	}
+	r := phase.Operations["READ"]
+	if r == nil || r.Count != 300 || r.Min != 1000 || r.P50 < 2000 || r.P50 > 2010 {
+		t.Fatalf("unexpected merged READ result %+v", r)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	p := properties.NewProperties()
+	p.Set("measurement.latency", "both")
+	InitMeasure(p)
+	ctx := InitThread(context.Background(), 0)
+	for _, op := range []string{"READ", "TOTAL", "INTENDED_READ", "INTENDED_TOTAL", "UPDATE_ERROR", "INTENDED_UPDATE_ERROR", "INTENDED_SCAN"} {
+		MeasureContext(ctx, op, "user1", time.Now(), time.Millisecond)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(collector{})
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]float64)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			name := f.GetName()
+			for _, l := range m.GetLabel() {
+				name += "/" + l.GetValue()
+			}
+			if h := m.GetHistogram(); h != nil {
+				got[name] = float64(h.GetSampleCount())
+			} else if c := m.GetCounter(); c != nil {
+				got[name] = c.GetValue()
+			}
+		}
+	}
+	// Each operation is counted once, and TOTAL is left out
+	want := map[string]float64{
+		"ycsb_operations_total/READ":                    1,
+		"ycsb_operations_total/UPDATE":                  1,
+		"ycsb_operations_total/SCAN":                    1,
+		"ycsb_operation_errors_total/UPDATE":            1,
+		"ycsb_operation_duration_seconds/READ":          1,
+		"ycsb_operation_intended_duration_seconds/READ": 1,
+		"ycsb_operation_intended_duration_seconds/SCAN": 1,
+	}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("want %s %v, got %v", name, v, got[name])
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("want the metrics %v, got %v", want, got)
+	}
+}
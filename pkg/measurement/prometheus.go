@@ -0,0 +1,157 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package measurement
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const errorSuffix = "_ERROR"
+
+// intendedPrefix starts the operations measured from their scheduled start,
+// and totalOp is the sum of the operations.
+const (
+	intendedPrefix = "INTENDED_"
+	totalOp        = "TOTAL"
+)
+
+// metricBuckets are the upper bounds of the latency histogram buckets, 50us
+// to 6.5s.
+var metricBuckets = prometheus.ExponentialBuckets(0.00005, 2, 18)
+
+// Metrics published on /metrics of the debug.pprof listener.
+var (
+	operationsDesc = prometheus.NewDesc("ycsb_operations_total",
+		"Number of completed operations, including failed ones.", []string{"operation"}, nil)
+	errorsDesc = prometheus.NewDesc("ycsb_operation_errors_total",
+		"Number of failed operations.", []string{"operation"}, nil)
+	latencyDesc = prometheus.NewDesc("ycsb_operation_duration_seconds",
+		"Latency of successful operations.", []string{"operation"}, nil)
+	intendedLatencyDesc = prometheus.NewDesc("ycsb_operation_intended_duration_seconds",
+		"Latency of successful throttled operations from their scheduled start.", []string{"operation"}, nil)
+	inFlightDesc = prometheus.NewDesc("ycsb_operations_in_flight",
+		"Number of workload operations issued and not yet completed.", nil, nil)
+)
+
+// collector collects the metrics kept by the shards when /metrics is
+// scraped, so that measuring doesn't update shared counters.
+type collector struct{}
+
+func (collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- operationsDesc
+	ch <- errorsDesc
+	ch <- latencyDesc
+	ch <- intendedLatencyDesc
+	ch <- inFlightDesc
+}
+
+func (collector) Collect(ch chan<- prometheus.Metric) {
+	m := globalMeasure
+	if m == nil || !m.metrics {
+		return
+	}
+
+	ops := make(map[string]*shardOp)
+	var inFlight int64
+	add := func(s *shard) {
+		inFlight += atomic.LoadInt64(&s.inFlight)
+		s.Lock()
+		defer s.Unlock()
+		for op, o := range s.ops {
+			to, ok := ops[op]
+			if !ok {
+				to = &shardOp{buckets: make([]uint64, len(o.buckets))}
+				ops[op] = to
+			}
+			to.count += o.count
+			to.sum += o.sum
+			for i, n := range o.buckets {
+				to.buckets[i] += n
+			}
+		}
+	}
+	m.Lock()
+	for _, s := range m.shards {
+		add(s)
+	}
+	add(m.fallback.shard)
+	m.Unlock()
+
+	// An operation is counted once, from its actual latencies unless only
+	// the intended ones are measured. TOTAL is left out, as Prometheus sums
+	// the operations.
+	var operations, failed [2]map[string]uint64
+	for kind := range operations {
+		operations[kind] = make(map[string]uint64)
+		failed[kind] = make(map[string]uint64)
+	}
+	for op, o := range ops {
+		name, intended := strings.CutPrefix(op, intendedPrefix)
+		kind := 0
+		if intended {
+			kind = 1
+		}
+		if name, isError := strings.CutSuffix(name, errorSuffix); isError {
+			if name != totalOp {
+				operations[kind][name] += o.count
+				failed[kind][name] += o.count
+			}
+			continue
+		}
+		if name == totalOp {
+			continue
+		}
+		operations[kind][name] += o.count
+
+		buckets := make(map[float64]uint64, len(metricBuckets))
+		var cumulative uint64
+		for i, upper := range metricBuckets {
+			cumulative += o.buckets[i]
+			buckets[upper] = cumulative
+		}
+		desc := latencyDesc
+		if intended {
+			desc = intendedLatencyDesc
+		}
+		ch <- prometheus.MustNewConstHistogram(desc, o.count, o.sum, buckets, name)
+	}
+	for kind := range operations {
+		for op, count := range operations[kind] {
+			if _, actual := operations[0][op]; kind == 1 && actual {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(operationsDesc, prometheus.CounterValue, float64(count), op)
+			if n := failed[kind][op]; n > 0 {
+				ch <- prometheus.MustNewConstMetric(errorsDesc, prometheus.CounterValue, float64(n), op)
+			}
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(inFlightDesc, prometheus.GaugeValue, float64(inFlight))
+}
+
+func init() {
+	prometheus.MustRegister(collector{})
+}
+
+// AddInFlight adds delta to the number of operations in flight of the thread
+// of ctx.
+func AddInFlight(ctx context.Context, delta int) {
+	if globalMeasure.metrics {
+		atomic.AddInt64(&threadRecorder(ctx).shard.inFlight, int64(delta))
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package measurement
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+)
+
+// ResultVersion is the version of the result file format.
+const ResultVersion = 1
+
+// Result is the machine-readable result of a run, written to
+// measurement.result_file.
+type Result struct {
+	Version  int    `json:"version"`
+	Command  string `json:"command"`
+	DB       string `json:"db"`
+	Workload string `json:"workload"`
+	// Revision is the VCS revision go-ycsb was built from, if known
+	Revision string    `json:"revision,omitempty"`
+	Host     Host      `json:"host"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+
+	Properties map[string]string `json:"properties"`
+	// Phases has the measurements of every phase of a schedule, or of the
+	// whole run as one unnamed phase
+	Phases []PhaseResult `json:"phases"`
+	// ServerStats are the statistics reported by the database, if it
+	// supports them
+	ServerStats map[string]interface{} `json:"server_stats,omitempty"`
+}
+
+// Host describes the machine go-ycsb ran on.
+type Host struct {
+	Hostname  string `json:"hostname"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	CPUs      int    `json:"cpus"`
+	GoVersion string `json:"go_version"`
+}
+
+// PhaseResult has the measurements of one phase of a run.
+type PhaseResult struct {
+	Name  string    `json:"name,omitempty"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	Operations map[string]*OperationResult `json:"operations"`
+	// Intervals are the interval reports of the phase
+	Intervals []IntervalResult `json:"intervals,omitempty"`
+}
+
+// OperationResult has the statistics of the successful operations of one
+// type, with latencies in us, and the number of failed ones.
+type OperationResult struct {
+	Count  int64   `json:"count"`
+	Errors int64   `json:"errors"`
+	Takes  float64 `json:"takes_s"`
+	OPS    float64 `json:"ops"`
+	Avg    int64   `json:"avg_us"`
+	Min    int64   `json:"min_us"`
+	Max    int64   `json:"max_us"`
+	P50    int64   `json:"p50_us"`
+	P90    int64   `json:"p90_us"`
+	P95    int64   `json:"p95_us"`
+	P99    int64   `json:"p99_us"`
+	P999   int64   `json:"p999_us"`
+	P9999  int64   `json:"p9999_us"`
+}
+
+// results returns the statistics of every operation. Failed operations,
+// measured as <op>_ERROR, are counted in the errors of op.
+func (h *histograms) results() map[string]*OperationResult {
+	results := make(map[string]*OperationResult, len(h.histograms))
+	get := func(op string) *OperationResult {
+		r, ok := results[op]
+		if !ok {
+			r = new(OperationResult)
+			results[op] = r
+		}
+		return r
+	}
+	for op, opM := range h.histograms {
+		hist := opM.hist
+		if name := strings.TrimSuffix(op, errorSuffix); name != op {
+			get(name).Errors = hist.TotalCount()
+			continue
+		}
+		r := get(op)
+		r.Count = hist.TotalCount()
+		r.Takes = time.Since(opM.startTime).Seconds()
+		r.OPS = float64(r.Count) / r.Takes
+		r.Avg = int64(hist.Mean())
+		r.Min = hist.Min()
+		r.Max = hist.Max()
+		r.P50 = hist.ValueAtPercentile(50)
+		r.P90 = hist.ValueAtPercentile(90)
+		r.P95 = hist.ValueAtPercentile(95)
+		r.P99 = hist.ValueAtPercentile(99)
+		r.P999 = hist.ValueAtPercentile(99.9)
+		r.P9999 = hist.ValueAtPercentile(99.99)
+	}
+	return results
+}
+
+// Phases returns the measurements of the phases output so far by Output and
+// OutputPhase.
+func Phases() []PhaseResult {
+	globalMeasure.Lock()
+	defer globalMeasure.Unlock()
+	return append([]PhaseResult(nil), globalMeasure.phases...)
+}
+
+// WriteResult writes r to path as indented JSON.
+func WriteResult(path string, r *Result) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ReadResult reads a result file written by WriteResult.
+func ReadResult(path string) (*Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := new(Result)
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
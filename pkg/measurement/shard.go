@@ -0,0 +1,118 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package measurement
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// shard records the latencies of some of the threads, so that threads don't
+// all contend on one lock. There is a shard per GOMAXPROCS, so that the
+// memory of their histograms doesn't grow with the number of threads. The
+// histograms are merged into the measurer and the interval reports, and
+// emptied, at every interval and at the end of the run.
+type shard struct {
+	sync.Mutex
+	ops map[string]*shardOp
+	// entries are the entries for the raw measurer not written yet
+	entries []csventry
+
+	// inFlight is updated and read atomically
+	inFlight int64
+}
+
+// shardOp keeps the latencies of one operation of a shard.
+type shardOp struct {
+	// created is when the operation was first measured
+	created time.Time
+	// hist has the latencies since the last merge
+	hist *hdrhistogram.Histogram
+
+	// Cumulative Prometheus metrics, nil buckets if disabled
+	count   uint64
+	sum     float64
+	buckets []uint64
+}
+
+func newShard() *shard {
+	return &shard{ops: make(map[string]*shardOp, 8)}
+}
+
+func (s *shard) measure(op string, key string, thread int, start time.Time, lan time.Duration, raw *csvs, metrics bool) {
+	var full []csventry
+	s.Lock()
+	o, ok := s.ops[op]
+	if !ok {
+		o = &shardOp{
+			created: time.Now(),
+			hist:    hdrhistogram.New(1, 24*60*60*1000*1000, 3),
+		}
+		if metrics {
+			o.buckets = make([]uint64, len(metricBuckets)+1)
+		}
+		s.ops[op] = o
+	}
+
+	o.hist.RecordValue(lan.Microseconds())
+	if raw != nil {
+		s.entries = append(s.entries, newCSVEntry(op, start, lan, thread, key))
+		if len(s.entries) >= rawBatchSize {
+			full = s.entries
+			s.entries = make([]csventry, 0, rawBatchSize)
+		}
+	}
+	if o.buckets != nil {
+		sec := lan.Seconds()
+		o.count++
+		o.sum += sec
+		o.buckets[sort.SearchFloat64s(metricBuckets, sec)]++
+	}
+	s.Unlock()
+
+	if full != nil {
+		raw.write(full)
+	}
+}
+
+// recorder is the latency recorder of a thread.
+type recorder struct {
+	shard  *shard
+	thread int
+}
+
+type recorderKey struct{}
+
+// InitThread returns a context that carries the latency recorder of one
+// thread. Operations measured with MeasureContext and this context are
+// recorded in a shard which the threads take in turn, so that they contend
+// with few other threads.
+func InitThread(ctx context.Context, threadID int) context.Context {
+	n := atomic.AddUint32(&globalMeasure.nextShard, 1)
+	s := globalMeasure.shards[int(n)%len(globalMeasure.shards)]
+	return context.WithValue(ctx, recorderKey{}, &recorder{shard: s, thread: threadID})
+}
+
+// threadRecorder returns the recorder of the thread of ctx, or the shared one.
+func threadRecorder(ctx context.Context) *recorder {
+	if r, ok := ctx.Value(recorderKey{}).(*recorder); ok {
+		return r
+	}
+	return &globalMeasure.fallback
+}
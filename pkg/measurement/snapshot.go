@@ -0,0 +1,164 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package measurement
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/magiconair/properties"
+)
+
+// Snapshot has the latencies of every operation over a period, encoded to be
+// sent to another process.
+type Snapshot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Operations has the latency histogram of every operation in the
+	// compressed, base64 encoded HdrHistogram format
+	Operations map[string]string `json:"operations"`
+}
+
+func newSnapshot(start time.Time, end time.Time, hists map[string]*hdrhistogram.Histogram) *Snapshot {
+	s := &Snapshot{Start: start, End: end, Operations: make(map[string]string, len(hists))}
+	for op, h := range hists {
+		data, err := h.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+		if err != nil {
+			panic("failed to encode histogram: " + err.Error())
+		}
+		s.Operations[op] = string(data)
+	}
+	return s
+}
+
+// histograms decodes the histograms of s.
+func (s *Snapshot) histograms() (map[string]*hdrhistogram.Histogram, error) {
+	hists := make(map[string]*hdrhistogram.Histogram, len(s.Operations))
+	for op, data := range s.Operations {
+		h, err := hdrhistogram.Decode([]byte(data))
+		if err != nil {
+			return nil, fmt.Errorf("decode histogram of %s failed: %w", op, err)
+		}
+		hists[op] = h
+	}
+	return hists, nil
+}
+
+// SetIntervalHandler sets a function called with the latencies of every
+// interval reported after it, or nil to stop calling it. The function is
+// called with the measurements locked and must not block.
+func SetIntervalHandler(handler func(s *Snapshot)) {
+	globalMeasure.Lock()
+	globalMeasure.intervals.handler = handler
+	globalMeasure.Unlock()
+}
+
+// TotalSnapshot returns the latencies of the current phase, such as of the
+// run after Output.
+func TotalSnapshot() *Snapshot {
+	globalMeasure.Lock()
+	defer globalMeasure.Unlock()
+	globalMeasure.mergeAll()
+	hists := make(map[string]*hdrhistogram.Histogram, len(globalMeasure.totals.histograms))
+	for op, opM := range globalMeasure.totals.histograms {
+		hists[op] = opM.hist
+	}
+	return newSnapshot(globalMeasure.phaseStart, time.Now(), hists)
+}
+
+// Merger merges the latencies measured by several processes, such as the
+// agents of a distributed run, into one report.
+type Merger struct {
+	intervals *intervals
+	totals    *histograms
+	// start is the earliest start of the totals
+	start time.Time
+}
+
+// NewMerger returns a merger which reports like the measurements of one
+// process with the properties p.
+func NewMerger(p *properties.Properties) *Merger {
+	return &Merger{
+		intervals: newIntervals(p),
+		totals:    InitHistograms(p),
+	}
+}
+
+// Interval reports one interval of every process, each with its own start
+// and end.
+func (m *Merger) Interval(snapshots []*Snapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	start, end := snapshots[0].Start, snapshots[0].End
+	for _, s := range snapshots[1:] {
+		if s.Start.Before(start) {
+			start = s.Start
+		}
+		if s.End.After(end) {
+			end = s.End
+		}
+	}
+
+	// The first interval starts the time series
+	m.intervals.reset(start)
+	for _, s := range snapshots {
+		hists, err := s.histograms()
+		if err != nil {
+			return err
+		}
+		for op, h := range hists {
+			m.intervals.merge(op, h)
+		}
+	}
+	m.intervals.report(end)
+	return nil
+}
+
+// AddTotals adds the latencies of the run of one process.
+func (m *Merger) AddTotals(s *Snapshot) error {
+	hists, err := s.histograms()
+	if err != nil {
+		return err
+	}
+	if m.start.IsZero() || s.Start.Before(m.start) {
+		m.start = s.Start
+	}
+	for op, h := range hists {
+		opM, ok := m.totals.histograms[op]
+		if !ok {
+			opM = newHistogram()
+			opM.startTime = s.Start
+			m.totals.histograms[op] = opM
+		} else if s.Start.Before(opM.startTime) {
+			opM.startTime = s.Start
+		}
+		opM.hist.Merge(h)
+	}
+	return nil
+}
+
+// Output writes the merged latencies of the run to w and returns them as a
+// result.
+func (m *Merger) Output(w io.Writer) (PhaseResult, error) {
+	r := PhaseResult{
+		Start:      m.start,
+		End:        time.Now(),
+		Operations: m.totals.results(),
+		Intervals:  m.intervals.takeResults(),
+	}
+	return r, m.totals.Output(w)
+}
@@ -37,6 +37,25 @@ const (
 	BatchSize          = "batch.size"
 	DefaultBatchSize   = int(1)
 
+	// With maxexecutiontime, the target ramps linearly to this rate over the run
+	TargetEnd = "target.end"
+	// "closed", "constant", "poisson" or "bursty". Except in closed mode, operations
+	// arrive at the target rate regardless of how long earlier ones take, and are
+	// dispatched to threadcount goroutines.
+	Arrival        = "arrival"
+	ArrivalDefault = "closed"
+	// Number of arrivals which may wait for a free goroutine before new ones are
+	// dropped (default: threadcount)
+	ArrivalQueue = "arrival.queue"
+	// Length of the on and off periods of the bursty arrival process (ms)
+	ArrivalBurstOn         = "arrival.burst.on"
+	ArrivalBurstOnDefault  = float64(1000)
+	ArrivalBurstOff        = "arrival.burst.off"
+	ArrivalBurstOffDefault = float64(1000)
+	// Comma separated phases run one after another. schedule.<phase>.<property>
+	// overrides a property in the phase, see client.ParseSchedule.
+	Schedule = "schedule"
+
 	TableName         = "table"
 	TableNameDefault  = "usertable"
 	FieldCount        = "fieldcount"
@@ -65,6 +84,8 @@ const (
 	ScanProportionDefault            = float64(0.0)
 	ReadModifyWriteProportion        = "readmodifywriteproportion"
 	ReadModifyWriteProportionDefault = float64(0.0)
+	CASProportion                    = "casproportion"
+	CASProportionDefault             = float64(0.0)
 	// "uniform", "zipfian", "latest"
 	RequestDistribution        = "requestdistribution"
 	RequestDistributionDefault = "uniform"
@@ -111,11 +132,39 @@ const (
 	KeySize        = "keysize"
 	KeySizeDefault = int64(64)
 
+	// RecordTrace is the trace file every operation is recorded to, zstd
+	// compressed if it ends in .zst. The format is "twemcache" or "jsonl".
+	RecordTrace              = "recordtrace"
+	RecordTraceFormat        = "recordtrace.format"
+	RecordTraceFormatDefault = "twemcache"
+
 	LogInterval = "measurement.interval"
 
 	MeasurementType          = "measurementtype"
 	MeasurementTypeDefault   = "histogram"
 	MeasurementRawOutputFile = "measurement.output_file"
+	// "op", "intended" or "both": with a target throughput, whether to measure
+	// latency from the actual start of operations, from the time the throttle
+	// scheduled them (as INTENDED_<op>, corrected for coordinated omission), or both
+	MeasurementLatency        = "measurement.latency"
+	MeasurementLatencyDefault = "both"
+
+	// Print the latencies of every measurement.interval
+	MeasurementIntervalPrint        = "measurement.interval.print"
+	MeasurementIntervalPrintDefault = true
+	// File the latencies of every measurement.interval are appended to, in the
+	// "csv" or "jsonl" measurement.timeseries_format (default: jsonl for .jsonl
+	// and .json files, else csv)
+	MeasurementTimeSeriesFile   = "measurement.timeseries_file"
+	MeasurementTimeSeriesFormat = "measurement.timeseries_format"
+
+	// Publish operation counters and latencies on /metrics of debug.pprof
+	MeasurementPrometheus        = "measurement.prometheus"
+	MeasurementPrometheusDefault = true
+
+	// JSON file the result of the run is written to, with the properties,
+	// host, numeric statistics of every operation and server-side statistics
+	MeasurementResultFile = "measurement.result_file"
 
 	Command = "command"
 
@@ -126,4 +175,11 @@ const (
 	MeasurementHistogramPercentileExportDefault         = false
 	MeasurementHistogramPercentileExportFilepath        = "histogram.percentiles.export.filepath"
 	MeasurementHistogramPercentileExportFilepathDefault = "./"
+
+	// Export the latencies of every measurement.interval to <filepath><op>.hlog
+	// in the HdrHistogram interval log format
+	MeasurementHistogramHlogExport                = "histogram.hlog.export"
+	MeasurementHistogramHlogExportDefault         = false
+	MeasurementHistogramHlogExportFilepath        = "histogram.hlog.export.filepath"
+	MeasurementHistogramHlogExportFilepathDefault = "./"
 )
@@ -89,6 +89,9 @@ func DecodeRow(b []byte) (map[int64][]byte, error) {
 }
 
 func decodeInt64(b []byte) ([]byte, int64, error) {
+	if len(b) == 0 || b[0] != varintFlag {
+		return nil, 0, errors.New("invalid flag to decode column id")
+	}
 	return decodeVarint(b[1:])
 }
 
@@ -104,11 +107,14 @@ func decodeVarint(b []byte) ([]byte, int64, error) {
 }
 
 func decodeBytes(b []byte) ([]byte, []byte, error) {
+	if len(b) == 0 || b[0] != compactBytesFlag {
+		return nil, nil, errors.New("invalid flag to decode value")
+	}
 	remain, n, err := decodeVarint(b[1:])
 	if err != nil {
 		return nil, nil, err
 	}
-	if int64(len(remain)) < n {
+	if n < 0 || int64(len(remain)) < n {
 		return nil, nil, errors.Errorf("insufficient bytes to decode value, expected length: %v", n)
 	}
 	return remain[n:], remain[:n], nil
@@ -36,3 +36,19 @@ func TestCodec(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeCorruptRow(t *testing.T) {
+	for _, b := range [][]byte{
+		{8},
+		{8, 0},
+		{8, 0, 2},
+		{8, 0, 2, 9, 'a', 'b'},
+		{8, 0, 2, 4, 'a'},
+		{2, 0, 2, 2, 'a'},
+		{8, 0, 8, 2, 'a'},
+	} {
+		if row, err := DecodeRow(b); err == nil {
+			t.Errorf("%q: want an error, got %q", b, row)
+		}
+	}
+}
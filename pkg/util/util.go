@@ -17,6 +17,7 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"strconv"
 	"sync"
 )
 
@@ -71,3 +72,18 @@ func (b *BufPool) Get() []byte {
 func (b *BufPool) Put(buf []byte) {
 	b.p.Put(buf)
 }
+
+// IncrementCounter adds delta to a counter stored as a decimal string and
+// returns the new value in both forms. An empty value counts as 0.
+func IncrementCounter(value []byte, delta int64) ([]byte, int64, error) {
+	var n int64
+	if len(value) > 0 {
+		var err error
+		n, err = strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("value %q is not an integer counter", value)
+		}
+	}
+	n += delta
+	return strconv.AppendInt(nil, n, 10), n, nil
+}
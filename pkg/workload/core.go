@@ -50,6 +50,7 @@ const (
 	insert
 	scan
 	readModifyWrite
+	compareAndSwap
 )
 
 // Core is the core benchmark scenario. Represents a set of clients doing simple CRUD operations.
@@ -108,6 +109,7 @@ func createOperationGenerator(p *properties.Properties) *generator.Discrete {
 	insertProportion := p.GetFloat64(prop.InsertProportion, prop.InsertProportionDefault)
 	scanProportion := p.GetFloat64(prop.ScanProportion, prop.ScanProportionDefault)
 	readModifyWriteProportion := p.GetFloat64(prop.ReadModifyWriteProportion, prop.ReadModifyWriteProportionDefault)
+	casProportion := p.GetFloat64(prop.CASProportion, prop.CASProportionDefault)
 
 	operationChooser := generator.NewDiscrete()
 	if readProportion > 0 {
@@ -130,6 +132,10 @@ func createOperationGenerator(p *properties.Properties) *generator.Discrete {
 		operationChooser.Add(readModifyWriteProportion, int64(readModifyWrite))
 	}
 
+	if casProportion > 0 {
+		operationChooser.Add(casProportion, int64(compareAndSwap))
+	}
+
 	return operationChooser
 }
 
@@ -372,6 +378,8 @@ func (c *core) DoTransaction(ctx context.Context, db ycsb.DB) error {
 		return c.doTransactionInsert(ctx, db, state)
 	case scan:
 		return c.doTransactionScan(ctx, db, state)
+	case compareAndSwap:
+		return c.doTransactionCompareAndSwap(ctx, db, state)
 	default:
 		return c.doTransactionReadModifyWrite(ctx, db, state)
 	}
@@ -442,13 +450,12 @@ func (c *core) doTransactionRead(ctx context.Context, db ycsb.DB, state *coreSta
 
 func (c *core) doTransactionReadModifyWrite(ctx context.Context, db ycsb.DB, state *coreState) error {
 	start := time.Now()
-	defer func() {
-		measurement.Measure("READ_MODIFY_WRITE", start, time.Now().Sub(start))
-	}()
-
 	r := state.r
 	keyNum := c.nextKeyNum(state)
 	keyName := c.buildKeyName(keyNum)
+	defer func() {
+		measurement.MeasureContext(ctx, "READ_MODIFY_WRITE", keyName, start, time.Now().Sub(start))
+	}()
 
 	var fields []string
 	if !c.readAllFields {
@@ -482,6 +489,30 @@ func (c *core) doTransactionReadModifyWrite(ctx context.Context, db ycsb.DB, sta
 	return nil
 }
 
+func (c *core) doTransactionCompareAndSwap(ctx context.Context, db ycsb.DB, state *coreState) error {
+	casDB, ok := db.(ycsb.CompareAndSwapDB)
+	if !ok {
+		return fmt.Errorf("the %T doesn't implement the CompareAndSwapDB interface", db)
+	}
+
+	r := state.r
+	keyNum := c.nextKeyNum(state)
+	keyName := c.buildKeyName(keyNum)
+	fieldName := state.fieldNames[c.fieldChooser.Next(r)]
+
+	readValues, err := db.Read(ctx, c.table, keyName, []string{fieldName})
+	if err != nil {
+		return err
+	}
+
+	// A fresh random value makes concurrent swaps of the same field conflict
+	value := c.buildRandomValue(state)
+	defer c.valuePool.Put(value)
+
+	_, err = casDB.CompareAndSwap(ctx, c.table, keyName, fieldName, readValues[fieldName], value)
+	return err
+}
+
 func (c *core) doTransactionInsert(ctx context.Context, db ycsb.DB, state *coreState) error {
 	r := state.r
 	keyNum := c.transactionInsertKeySequence.Next(r)
@@ -0,0 +1,195 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workload
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The compact binary trace format written by trace-convert. It starts with
+// traceBinaryMagic, followed by one entry per record:
+//
+//	uvarint operation id, uvarint key id, uvarint client id
+//	varint  timestamp delta from the previous record (us)
+//	uvarint key size, uvarint value size, varint ttl
+//
+// Operations, keys and clients are dictionary-encoded: an id equal to the
+// number of distinct values seen so far introduces a new value, followed by its
+// uvarint length and bytes. Decoding therefore needs no parsing and shares one
+// string per distinct key, which makes loading much faster than CSV.
+const traceBinaryMagic = "YCSBTRC\x01"
+
+// maxTraceBinaryString is the longest operation, key or client of the binary
+// format, so that a corrupt length can't make the decoder allocate gigabytes.
+// It is far above the 250 bytes of a memcached key.
+const maxTraceBinaryString = 64 * 1024
+
+// traceBinaryWriter encodes records in the compact binary format.
+type traceBinaryWriter struct {
+	w *bufio.Writer
+	// Dictionary ids of the operations, keys and clients written so far
+	ops, keys, clients map[string]uint64
+	lastTimestamp      int64
+	buf                []byte
+	wroteHeader        bool
+}
+
+func newTraceBinaryWriter(w io.Writer) *traceBinaryWriter {
+	return &traceBinaryWriter{
+		w:       bufio.NewWriterSize(w, 64*1024),
+		ops:     make(map[string]uint64),
+		keys:    make(map[string]uint64),
+		clients: make(map[string]uint64),
+	}
+}
+
+func (t *traceBinaryWriter) appendString(ids map[string]uint64, s string) error {
+	id, ok := ids[s]
+	if ok {
+		t.buf = binary.AppendUvarint(t.buf, id)
+		return nil
+	}
+	if len(s) > maxTraceBinaryString {
+		return fmt.Errorf("binary trace value of %d bytes is longer than %d", len(s), maxTraceBinaryString)
+	}
+	id = uint64(len(ids))
+	ids[s] = id
+	t.buf = binary.AppendUvarint(t.buf, id)
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(s)))
+	t.buf = append(t.buf, s...)
+	return nil
+}
+
+// Write encodes one record.
+func (t *traceBinaryWriter) Write(r *traceRecord) error {
+	t.buf = t.buf[:0]
+	if !t.wroteHeader {
+		t.buf = append(t.buf, traceBinaryMagic...)
+		t.wroteHeader = true
+	}
+	for _, v := range []struct {
+		ids map[string]uint64
+		s   string
+	}{{t.ops, r.operation}, {t.keys, r.key}, {t.clients, r.clientID}} {
+		if err := t.appendString(v.ids, v.s); err != nil {
+			return err
+		}
+	}
+
+	ts := int64(math.Round(r.timestamp * 1e6))
+	t.buf = binary.AppendVarint(t.buf, ts-t.lastTimestamp)
+	t.lastTimestamp = ts
+
+	t.buf = binary.AppendUvarint(t.buf, uint64(r.keySize))
+	t.buf = binary.AppendUvarint(t.buf, uint64(r.valueSize))
+	t.buf = binary.AppendVarint(t.buf, int64(r.ttl))
+	_, err := t.w.Write(t.buf)
+	return err
+}
+
+// Flush writes out buffered records.
+func (t *traceBinaryWriter) Flush() error {
+	if !t.wroteHeader {
+		if _, err := t.w.WriteString(traceBinaryMagic); err != nil {
+			return err
+		}
+		t.wroteHeader = true
+	}
+	return t.w.Flush()
+}
+
+// traceBinaryDecoder decodes records of the compact binary format. The magic
+// must already have been consumed.
+type traceBinaryDecoder struct {
+	r                  *bufio.Reader
+	ops, keys, clients []string
+	lastTimestamp      int64
+}
+
+func (d *traceBinaryDecoder) readString(values *[]string) (string, error) {
+	id, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return "", err
+	}
+	if id < uint64(len(*values)) {
+		return (*values)[id], nil
+	}
+	if id != uint64(len(*values)) {
+		return "", fmt.Errorf("corrupt binary trace: unknown dictionary id %d", id)
+	}
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return "", err
+	}
+	if n > maxTraceBinaryString {
+		return "", fmt.Errorf("corrupt binary trace: value of %d bytes", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return "", err
+	}
+	*values = append(*values, string(b))
+	return (*values)[id], nil
+}
+
+// Next returns the next record, or io.EOF at the end of the trace.
+func (d *traceBinaryDecoder) Next() (TraceRecord, error) {
+	op, err := d.readString(&d.ops)
+	if err != nil {
+		// A clean end of file can only happen between records
+		return TraceRecord{}, err
+	}
+	var r TraceRecord
+	r.Operation = op
+	if r.Key, err = d.readString(&d.keys); err != nil {
+		return TraceRecord{}, unexpectedEOF(err)
+	}
+	if r.ClientID, err = d.readString(&d.clients); err != nil {
+		return TraceRecord{}, unexpectedEOF(err)
+	}
+
+	delta, err := binary.ReadVarint(d.r)
+	if err != nil {
+		return TraceRecord{}, unexpectedEOF(err)
+	}
+	d.lastTimestamp += delta
+	r.Timestamp = float64(d.lastTimestamp) / 1e6
+
+	var v uint64
+	if v, err = binary.ReadUvarint(d.r); err != nil {
+		return TraceRecord{}, unexpectedEOF(err)
+	}
+	r.KeySize = int(v)
+	if v, err = binary.ReadUvarint(d.r); err != nil {
+		return TraceRecord{}, unexpectedEOF(err)
+	}
+	r.ValueSize = int(v)
+	ttl, err := binary.ReadVarint(d.r)
+	if err != nil {
+		return TraceRecord{}, unexpectedEOF(err)
+	}
+	r.TTL = int(ttl)
+	return r, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,214 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workload
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Output formats of ConvertTrace
+const (
+	TraceOutputCSV    = "csv"
+	TraceOutputBinary = "binary"
+)
+
+// TraceConvertOptions selects the records ConvertTrace keeps and how they are
+// written.
+type TraceConvertOptions struct {
+	// InputFormat is the registered trace format of the input (empty = auto)
+	InputFormat string
+	// MaxRecords limits the number of input records read (0 = unlimited)
+	MaxRecords int64
+	// Start and End select the time window [Start, End) in seconds relative
+	// to the first record. End <= 0 means until the end of the trace.
+	Start, End float64
+	// SampleRate keeps this fraction of the keys, chosen by hashing the key so
+	// that every access to a kept key is kept (0 or 1 = all keys)
+	SampleRate float64
+	// Operations and Clients keep only records with these operations or
+	// client ids (empty = all)
+	Operations []string
+	Clients    []string
+	// RenameKeys replaces keys with short sequential names in order of first
+	// appearance
+	RenameKeys bool
+	// Format is TraceOutputCSV or TraceOutputBinary. The output is zstd
+	// compressed if its name ends in .zst or .zstd.
+	Format string
+}
+
+// TraceConvertResult counts the records read and written by ConvertTrace.
+type TraceConvertResult struct {
+	Read    int64
+	Written int64
+	Keys    int64
+}
+
+// traceRecordWriter writes trace records in one of the output formats.
+type traceRecordWriter interface {
+	Write(r *traceRecord) error
+	Flush() error
+}
+
+// traceCSVWriter writes records in the twemcache CSV format.
+type traceCSVWriter struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+func (t *traceCSVWriter) Write(r *traceRecord) error {
+	b := t.buf[:0]
+	b = strconv.AppendFloat(b, r.timestamp, 'f', -1, 64)
+	b = append(b, ',')
+	b = append(b, r.key...)
+	b = append(b, ',')
+	b = strconv.AppendInt(b, int64(r.keySize), 10)
+	b = append(b, ',')
+	b = strconv.AppendInt(b, int64(r.valueSize), 10)
+	b = append(b, ',')
+	b = append(b, r.clientID...)
+	b = append(b, ',')
+	b = append(b, r.operation...)
+	b = append(b, ',')
+	b = strconv.AppendInt(b, int64(r.ttl), 10)
+	b = append(b, '\n')
+	t.buf = b
+	_, err := t.w.Write(b)
+	return err
+}
+
+func (t *traceCSVWriter) Flush() error {
+	return t.w.Flush()
+}
+
+// traceKeySampled reports whether a key falls in the hash-based sample.
+func traceKeySampled(key string, rate float64) bool {
+	if rate <= 0 || rate >= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return float64(h.Sum64()%1000000) < rate*1000000
+}
+
+func stringSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// ConvertTrace reads a trace in any format registered with
+// RegisterTraceFormat and writes the selected records to outPath.
+func ConvertTrace(inPath string, outPath string, opts TraceConvertOptions) (TraceConvertResult, error) {
+	var res TraceConvertResult
+	if opts.Format != TraceOutputCSV && opts.Format != TraceOutputBinary {
+		return res, fmt.Errorf("unknown output format '%s', must be '%s' or '%s'", opts.Format, TraceOutputCSV, TraceOutputBinary)
+	}
+
+	reader, err := newTraceReader(inPath, opts.InputFormat)
+	if err != nil {
+		return res, err
+	}
+	defer reader.Close()
+
+	file, err := os.Create(outPath)
+	if err != nil {
+		return res, fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+
+	var out io.Writer = file
+	var encoder *zstd.Encoder
+	if strings.HasSuffix(outPath, ".zst") || strings.HasSuffix(outPath, ".zstd") {
+		if encoder, err = zstd.NewWriter(file); err != nil {
+			return res, fmt.Errorf("failed to create zstd encoder: %w", err)
+		}
+		out = encoder
+	}
+
+	var writer traceRecordWriter
+	if opts.Format == TraceOutputBinary {
+		writer = newTraceBinaryWriter(out)
+	} else {
+		writer = &traceCSVWriter{w: bufio.NewWriterSize(out, 64*1024)}
+	}
+
+	ops := stringSet(opts.Operations)
+	clients := stringSet(opts.Clients)
+	keys := make(map[string]string)
+	var start float64
+	for opts.MaxRecords <= 0 || res.Read < opts.MaxRecords {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, err
+		}
+		if res.Read == 0 {
+			start = record.timestamp
+		}
+		res.Read++
+
+		offset := record.timestamp - start
+		if offset < opts.Start || (opts.End > 0 && offset >= opts.End) {
+			continue
+		}
+		if (ops != nil && !ops[record.operation]) || (clients != nil && !clients[record.clientID]) {
+			continue
+		}
+		if !traceKeySampled(record.key, opts.SampleRate) {
+			continue
+		}
+
+		name, ok := keys[record.key]
+		if !ok {
+			name = record.key
+			if opts.RenameKeys {
+				name = "k" + strconv.Itoa(len(keys))
+			}
+			keys[record.key] = name
+		}
+		record.key = name
+
+		if err := writer.Write(&record); err != nil {
+			return res, err
+		}
+		res.Written++
+	}
+	res.Keys = int64(len(keys))
+
+	if err := writer.Flush(); err != nil {
+		return res, err
+	}
+	if encoder != nil {
+		if err := encoder.Close(); err != nil {
+			return res, err
+		}
+	}
+	return res, file.Close()
+}
@@ -0,0 +1,98 @@
+package workload
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertTraceBinary(t *testing.T) {
+	in := writeTestTrace(t, "trace.csv")
+	want, err := parseTraceFile(in, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"trace.bin", "trace.bin.zst", "trace.csv.zst"} {
+		format := TraceOutputBinary
+		if name == "trace.csv.zst" {
+			format = TraceOutputCSV
+		}
+		out := filepath.Join(t.TempDir(), name)
+		res, err := ConvertTrace(in, out, TraceConvertOptions{Format: format})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Read != 5 || res.Written != 5 || res.Keys != 3 {
+			t.Errorf("%s: unexpected result %+v", name, res)
+		}
+
+		got, err := parseTraceFile(out, "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: want %d records, got %d", name, len(want), len(got))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: record %d: want %+v, got %+v", name, i, want[i], got[i])
+			}
+		}
+	}
+}
+
+func TestTraceBinaryCorrupt(t *testing.T) {
+	newOp := []byte(traceBinaryMagic + "\x00")
+	for name, data := range map[string][]byte{
+		// A length which would allocate a terabyte
+		"huge length": binary.AppendUvarint(newOp, 1<<40),
+		"truncated":   append(binary.AppendUvarint(newOp, 3), "ge"...),
+		"unknown id":  []byte(traceBinaryMagic + "\x05"),
+	} {
+		path := filepath.Join(t.TempDir(), "trace.bin")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parseTraceFile(path, TraceFormatBinary, 0); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
+
+func TestConvertTraceFilters(t *testing.T) {
+	in := writeTestTrace(t, "trace.csv")
+	out := filepath.Join(t.TempDir(), "trace.bin")
+	_, err := ConvertTrace(in, out, TraceConvertOptions{
+		Format:     TraceOutputBinary,
+		End:        2,
+		Operations: []string{"set"},
+		RenameKeys: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseTraceFile(out, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].key != "k0" || got[1].key != "k1" || got[1].timestamp != 1 {
+		t.Errorf("unexpected records %+v", got)
+	}
+
+	// Key sampling keeps every access of the sampled keys
+	res, err := ConvertTrace(in, out, TraceConvertOptions{Format: TraceOutputCSV, SampleRate: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, key := range []string{"keyA", "keyB", "keyC"} {
+		if traceKeySampled(key, 0.5) {
+			n += map[string]int{"keyA": 3, "keyB": 1, "keyC": 1}[key]
+		}
+	}
+	if res.Written != int64(n) {
+		t.Errorf("want %d sampled records, got %d", n, res.Written)
+	}
+}
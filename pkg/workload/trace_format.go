@@ -0,0 +1,304 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workload
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Trace formats built into the trace workload
+const (
+	// TraceFormatAuto recognises the binary format by its magic and reads
+	// anything else as twemcache CSV
+	TraceFormatAuto = "auto"
+	// TraceFormatTwemcache is the 7-column CSV format of Twitter's cache traces
+	TraceFormatTwemcache = "twemcache"
+	// TraceFormatBinary is the compact format written by trace-convert
+	TraceFormatBinary = "binary"
+	// TraceFormatJSONLines has one JSON object per line
+	TraceFormatJSONLines = "jsonl"
+	// TraceFormatOracleGeneral is the oracleGeneral binary format of libCacheSim
+	TraceFormatOracleGeneral = "oraclegeneral"
+)
+
+// TraceRecord is one request of a trace as decoded by a TraceFormat.
+type TraceRecord struct {
+	// Timestamp is the time of the request (sec)
+	Timestamp float64
+	Key       string
+	KeySize   int
+	ValueSize int
+	ClientID  string
+	// Operation is a memcached command such as get, set or delete
+	Operation string
+	// TTL is the expiry time of written keys (sec, 0 = none)
+	TTL int
+}
+
+// traceOps are the memcached commands of traces, which traceOp interns.
+var traceOps = map[string]string{}
+
+func init() {
+	for _, op := range []string{"get", "gets", "set", "add", "replace", "cas", "append", "prepend", "delete", "incr", "decr"} {
+		traceOps[op] = op
+	}
+}
+
+// traceOp returns op as one of the known commands, or a copy of it, so that
+// a kept record doesn't pin the line it was decoded from.
+func traceOp(op string) string {
+	if known, ok := traceOps[op]; ok {
+		return known
+	}
+	return strings.Clone(op)
+}
+
+// TraceDecoder decodes the records of one trace file.
+type TraceDecoder interface {
+	// Next returns the next record, or io.EOF at the end of the trace.
+	// Malformed records are skipped rather than returned as errors.
+	Next() (TraceRecord, error)
+}
+
+// TraceFormat creates decoders for a trace file format.
+type TraceFormat interface {
+	// NewDecoder returns a decoder for the decompressed contents of a trace file.
+	NewDecoder(r *bufio.Reader) (TraceDecoder, error)
+}
+
+var traceFormats = map[string]TraceFormat{}
+
+// RegisterTraceFormat registers a trace file format, selected by trace.format
+func RegisterTraceFormat(name string, format TraceFormat) {
+	_, ok := traceFormats[name]
+	if ok {
+		panic(fmt.Sprintf("duplicate register trace format %s", name))
+	}
+
+	traceFormats[name] = format
+}
+
+// GetTraceFormat gets the TraceFormat for the name
+func GetTraceFormat(name string) TraceFormat {
+	return traceFormats[name]
+}
+
+// traceFormatNames returns the names of the registered formats.
+func traceFormatNames() []string {
+	names := make([]string, 0, len(traceFormats))
+	for name := range traceFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkTraceFormat returns an error if no format is registered under name.
+func checkTraceFormat(name string) error {
+	if GetTraceFormat(name) == nil {
+		return fmt.Errorf("unknown trace format '%s', must be one of %s", name, strings.Join(traceFormatNames(), ", "))
+	}
+	return nil
+}
+
+type traceAutoFormat struct{}
+
+func (traceAutoFormat) NewDecoder(r *bufio.Reader) (TraceDecoder, error) {
+	if magic, _ := r.Peek(len(traceBinaryMagic)); string(magic) == traceBinaryMagic {
+		return traceBinaryFormat{}.NewDecoder(r)
+	}
+	return traceTwemcacheFormat{}.NewDecoder(r)
+}
+
+type traceBinaryFormat struct{}
+
+func (traceBinaryFormat) NewDecoder(r *bufio.Reader) (TraceDecoder, error) {
+	magic := make([]byte, len(traceBinaryMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != traceBinaryMagic {
+		return nil, fmt.Errorf("not a binary trace file")
+	}
+	return &traceBinaryDecoder{r: r}, nil
+}
+
+type traceTwemcacheFormat struct{}
+
+func (traceTwemcacheFormat) NewDecoder(r *bufio.Reader) (TraceDecoder, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.ReuseRecord = true
+	return &traceTwemcacheDecoder{r: csvReader}, nil
+}
+
+// traceTwemcacheDecoder decodes lines of
+// timestamp, key, key_size, value_size, client_id, operation, ttl
+type traceTwemcacheDecoder struct {
+	r *csv.Reader
+}
+
+func (d *traceTwemcacheDecoder) Next() (TraceRecord, error) {
+	for {
+		record, err := d.r.Read()
+		if err == io.EOF {
+			return TraceRecord{}, io.EOF
+		}
+		if err != nil {
+			// Skip malformed lines
+			continue
+		}
+
+		// Skip if not enough fields
+		if len(record) < 7 {
+			continue
+		}
+
+		// Parse fields: timestamp, key, key_size, value_size, client_id, op, ttl
+		timestamp, _ := strconv.ParseFloat(record[0], 64)
+		keySize, _ := strconv.Atoi(record[2])
+		valueSize, _ := strconv.Atoi(record[3])
+		ttl, _ := strconv.Atoi(record[6])
+
+		// Clone the strings we keep so they don't pin the whole CSV line in memory
+		return TraceRecord{
+			Timestamp: timestamp,
+			Key:       strings.Clone(record[1]),
+			KeySize:   keySize,
+			ValueSize: valueSize,
+			ClientID:  strings.Clone(record[4]),
+			Operation: traceOp(record[5]),
+			TTL:       ttl,
+		}, nil
+	}
+}
+
+type traceJSONLinesFormat struct{}
+
+func (traceJSONLinesFormat) NewDecoder(r *bufio.Reader) (TraceDecoder, error) {
+	return &traceJSONLinesDecoder{r: r}, nil
+}
+
+// traceJSONString accepts both JSON strings and numbers, for client ids.
+type traceJSONString string
+
+func (s *traceJSONString) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, (*string)(s))
+	}
+	if string(data) == "null" {
+		*s = ""
+		return nil
+	}
+	*s = traceJSONString(data)
+	return nil
+}
+
+// traceJSONRecord is one line of a JSON-lines trace. key and op are required,
+// key_size defaults to the length of the key.
+type traceJSONRecord struct {
+	Timestamp float64         `json:"timestamp"`
+	Key       string          `json:"key"`
+	KeySize   *int            `json:"key_size"`
+	ValueSize int             `json:"value_size"`
+	ClientID  traceJSONString `json:"client_id"`
+	Op        string          `json:"op"`
+	TTL       int             `json:"ttl"`
+}
+
+type traceJSONLinesDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *traceJSONLinesDecoder) Next() (TraceRecord, error) {
+	for {
+		line, err := d.r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// Skip lines longer than the buffer
+			for err == bufio.ErrBufferFull {
+				_, err = d.r.ReadSlice('\n')
+			}
+			continue
+		}
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return TraceRecord{}, err
+		}
+
+		var record traceJSONRecord
+		if json.Unmarshal(line, &record) != nil || record.Key == "" || record.Op == "" {
+			// Skip blank and malformed lines
+			if err == io.EOF {
+				return TraceRecord{}, io.EOF
+			}
+			continue
+		}
+		keySize := len(record.Key)
+		if record.KeySize != nil {
+			keySize = *record.KeySize
+		}
+		return TraceRecord{
+			Timestamp: record.Timestamp,
+			Key:       record.Key,
+			KeySize:   keySize,
+			ValueSize: record.ValueSize,
+			ClientID:  string(record.ClientID),
+			Operation: record.Op,
+			TTL:       record.TTL,
+		}, nil
+	}
+}
+
+// oracleGeneralRecordSize is the size of one oracleGeneral request:
+// uint32 timestamp, uint64 object id, uint32 object size and int64 next
+// access, all little endian.
+const oracleGeneralRecordSize = 24
+
+type traceOracleGeneralFormat struct{}
+
+func (traceOracleGeneralFormat) NewDecoder(r *bufio.Reader) (TraceDecoder, error) {
+	return &traceOracleGeneralDecoder{r: r}, nil
+}
+
+// traceOracleGeneralDecoder decodes libCacheSim oracleGeneral traces. The
+// format has no operations, every request is a get of the object id.
+type traceOracleGeneralDecoder struct {
+	r   *bufio.Reader
+	buf [oracleGeneralRecordSize]byte
+}
+
+func (d *traceOracleGeneralDecoder) Next() (TraceRecord, error) {
+	if _, err := io.ReadFull(d.r, d.buf[:]); err != nil {
+		return TraceRecord{}, err
+	}
+	key := strconv.FormatUint(binary.LittleEndian.Uint64(d.buf[4:12]), 10)
+	return TraceRecord{
+		Timestamp: float64(binary.LittleEndian.Uint32(d.buf[0:4])),
+		Key:       key,
+		KeySize:   len(key),
+		ValueSize: int(binary.LittleEndian.Uint32(d.buf[12:16])),
+		Operation: "get",
+	}, nil
+}
+
+func init() {
+	RegisterTraceFormat(TraceFormatAuto, traceAutoFormat{})
+	RegisterTraceFormat(TraceFormatTwemcache, traceTwemcacheFormat{})
+	RegisterTraceFormat(TraceFormatBinary, traceBinaryFormat{})
+	RegisterTraceFormat(TraceFormatJSONLines, traceJSONLinesFormat{})
+	RegisterTraceFormat(TraceFormatOracleGeneral, traceOracleGeneralFormat{})
+}
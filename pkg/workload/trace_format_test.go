@@ -0,0 +1,82 @@
+package workload
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTraceFormatJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	data := `{"timestamp": 1.5, "key": "keyA", "value_size": 10, "client_id": 7, "op": "GET"}
+not json
+
+{"timestamp": 2, "key": "keyB", "key_size": 8, "value_size": 20, "client_id": "c2", "op": "set", "ttl": 60}
+{"timestamp": 3, "key": "keyC"}
+{"timestamp": 4, "key": "keyC", "op": "delete"}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := parseTraceFile(path, TraceFormatJSONLines, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []traceRecord{
+		{timestamp: 1.5, key: "keyA", keySize: 4, valueSize: 10, clientID: "7", operation: "get"},
+		{timestamp: 2, key: "keyB", keySize: 8, valueSize: 20, clientID: "c2", operation: "set", ttl: 60},
+		{timestamp: 4, key: "keyC", keySize: 4, operation: "delete"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("want %d records, got %+v", len(want), records)
+	}
+	for i := range want {
+		if records[i] != want[i] {
+			t.Errorf("record %d: want %+v, got %+v", i, want[i], records[i])
+		}
+	}
+}
+
+func TestTraceFormatOracleGeneral(t *testing.T) {
+	var data []byte
+	for _, r := range []struct {
+		ts   uint32
+		id   uint64
+		size uint32
+	}{{10, 42, 100}, {11, 7, 200}, {11, 42, 100}} {
+		data = binary.LittleEndian.AppendUint32(data, r.ts)
+		data = binary.LittleEndian.AppendUint64(data, r.id)
+		data = binary.LittleEndian.AppendUint32(data, r.size)
+		data = binary.LittleEndian.AppendUint64(data, ^uint64(0))
+	}
+	path := filepath.Join(t.TempDir(), "trace.oracleGeneral.bin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := scanTraceKeys(path, TraceFormatOracleGeneral, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.records != 3 || len(s.keys) != 2 || s.keys[0] != "42" || s.valueSizes["7"] != 200 || s.reads != 3 {
+		t.Errorf("unexpected key set %+v", s)
+	}
+	if s.minTimestamp != 10 || s.maxTimestamp != 11 {
+		t.Errorf("unexpected timestamps %v-%v", s.minTimestamp, s.maxTimestamp)
+	}
+
+	// A truncated record is an error
+	if err := os.WriteFile(path, data[:len(data)-1], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseTraceFile(path, TraceFormatOracleGeneral, 0); err == nil {
+		t.Error("expected an error for a truncated trace")
+	}
+}
+
+func TestTraceFormatUnknown(t *testing.T) {
+	if _, err := parseTraceFile(writeTestTrace(t, "trace.csv"), "nope", 0); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,460 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workload
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// traceReader decodes a trace file (supports .zst compression) one record at
+// a time with one of the registered trace formats, so that traces larger than
+// memory can be replayed.
+type traceReader struct {
+	file    *os.File
+	decoder *zstd.Decoder
+	trace   TraceDecoder
+}
+
+// newTraceReader opens a trace file in the given format (empty for
+// TraceFormatAuto) for incremental decoding.
+func newTraceReader(filePath string, format string) (*traceReader, error) {
+	if format == "" {
+		format = TraceFormatAuto
+	}
+	if err := checkTraceFormat(format); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+
+	r := &traceReader{file: file}
+	var reader io.Reader = file
+
+	// Check if file is zstd compressed
+	if strings.HasSuffix(filePath, ".zst") || strings.HasSuffix(filePath, ".zstd") {
+		decoder, err := zstd.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+		}
+		r.decoder = decoder
+		reader = decoder
+	}
+
+	// Use buffered reader for better performance
+	r.trace, err = GetTraceFormat(format).NewDecoder(bufio.NewReaderSize(reader, 64*1024))
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to decode %s trace: %w", format, err)
+	}
+	return r, nil
+}
+
+// Next returns the next well-formed record, or io.EOF at the end of the trace.
+func (r *traceReader) Next() (traceRecord, error) {
+	record, err := r.trace.Next()
+	if err != nil {
+		return traceRecord{}, err
+	}
+	return traceRecord{
+		timestamp: record.Timestamp,
+		key:       record.Key,
+		keySize:   record.KeySize,
+		valueSize: record.ValueSize,
+		clientID:  record.ClientID,
+		operation: traceOp(strings.ToLower(record.Operation)),
+		ttl:       record.TTL,
+	}, nil
+}
+
+// Close releases the underlying file and decoder.
+func (r *traceReader) Close() error {
+	if r.decoder != nil {
+		r.decoder.Close()
+	}
+	return r.file.Close()
+}
+
+// parseTraceFile reads and parses a whole trace file into memory
+func parseTraceFile(filePath string, format string, maxRecords int64) ([]traceRecord, error) {
+	reader, err := newTraceReader(filePath, format)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var records []traceRecord
+	for maxRecords <= 0 || int64(len(records)) < maxRecords {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// traceSizeCount counts how often a key was written with a given value size.
+type traceSizeCount struct {
+	size  int
+	count int
+}
+
+// traceKeySet accumulates the load-phase key set and operation mix of a trace
+// in one pass. Its memory use depends on the number of unique keys, not on the
+// number of records.
+type traceKeySet struct {
+	keys       []string // unique keys in order of first appearance
+	keySizes   map[string]int
+	valueSizes map[string]int      // largest value size per key
+	counters   map[string]struct{} // keys that are incremented or decremented
+
+	// Histogram of non-zero value sizes per key, used to compute the median
+	// value size in deterministic values mode
+	sizeCounts map[string][]traceSizeCount
+
+	records                       int64
+	reads, writes, deletes, other int64
+	minTimestamp, maxTimestamp    float64
+	trackMedian, haveTimestamp    bool
+}
+
+func newTraceKeySet(trackMedian bool) *traceKeySet {
+	s := &traceKeySet{
+		keySizes:    make(map[string]int),
+		valueSizes:  make(map[string]int),
+		counters:    make(map[string]struct{}),
+		trackMedian: trackMedian,
+	}
+	if trackMedian {
+		s.sizeCounts = make(map[string][]traceSizeCount)
+	}
+	return s
+}
+
+// add accounts for one trace record.
+func (s *traceKeySet) add(r *traceRecord) {
+	s.records++
+	if !s.haveTimestamp {
+		s.minTimestamp, s.maxTimestamp = r.timestamp, r.timestamp
+		s.haveTimestamp = true
+	}
+	if r.timestamp < s.minTimestamp {
+		s.minTimestamp = r.timestamp
+	}
+	if r.timestamp > s.maxTimestamp {
+		s.maxTimestamp = r.timestamp
+	}
+
+	if _, ok := s.keySizes[r.key]; !ok {
+		s.keys = append(s.keys, r.key)
+		s.keySizes[r.key] = r.keySize
+	}
+	if r.operation == "scan" {
+		// The value size of a scan is its count
+		s.reads++
+		return
+	}
+	// Keep track of the largest value size for each key
+	if r.valueSize > s.valueSizes[r.key] {
+		s.valueSizes[r.key] = r.valueSize
+	}
+	if s.trackMedian && r.valueSize > 0 {
+		s.sizeCounts[r.key] = addSizeCount(s.sizeCounts[r.key], r.valueSize)
+	}
+
+	switch r.operation {
+	case "get", "gets":
+		s.reads++
+	case "set", "add", "replace", "cas", "append", "prepend":
+		s.writes++
+	case "delete":
+		s.deletes++
+	case "incr", "decr":
+		s.counters[r.key] = struct{}{}
+		s.other++
+	default:
+		s.other++
+	}
+}
+
+func addSizeCount(counts []traceSizeCount, size int) []traceSizeCount {
+	for i := range counts {
+		if counts[i].size == size {
+			counts[i].count++
+			return counts
+		}
+	}
+	return append(counts, traceSizeCount{size: size, count: 1})
+}
+
+// medianSizeCount returns the median of the value sizes described by counts.
+func medianSizeCount(counts []traceSizeCount) int {
+	sort.Slice(counts, func(i, j int) bool { return counts[i].size < counts[j].size })
+	n := 0
+	for _, c := range counts {
+		n += c.count
+	}
+	if n == 0 {
+		return 0
+	}
+
+	// nth returns the i-th smallest size (0-based)
+	nth := func(i int) int {
+		for _, c := range counts {
+			if i < c.count {
+				return c.size
+			}
+			i -= c.count
+		}
+		return 0
+	}
+	if n%2 == 1 {
+		return nth(n / 2)
+	}
+	return (nth(n/2-1) + nth(n/2)) / 2
+}
+
+// finish replaces valueSizes with per-key medians in deterministic values mode.
+func (s *traceKeySet) finish() {
+	if !s.trackMedian {
+		return
+	}
+	for key, counts := range s.sizeCounts {
+		s.valueSizes[key] = medianSizeCount(counts)
+	}
+	s.sizeCounts = nil
+}
+
+// printBreakdown prints the operation mix seen by the key set.
+func (s *traceKeySet) printBreakdown() {
+	if s.records == 0 {
+		return
+	}
+	fmt.Printf("Operation breakdown: reads=%d (%.1f%%), writes=%d (%.1f%%), deletes=%d, other=%d\n",
+		s.reads, float64(s.reads)*100/float64(s.records),
+		s.writes, float64(s.writes)*100/float64(s.records),
+		s.deletes, s.other)
+}
+
+// scanTraceKeys makes one streaming pass over a trace file to compute its key
+// set without keeping the records in memory.
+func scanTraceKeys(filePath string, format string, maxRecords int64, trackMedian bool) (*traceKeySet, error) {
+	reader, err := newTraceReader(filePath, format)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	s := newTraceKeySet(trackMedian)
+	for maxRecords <= 0 || s.records < maxRecords {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.add(&record)
+	}
+	s.finish()
+	return s, nil
+}
+
+// traceItem is a record handed from the streaming reader to a worker.
+type traceItem struct {
+	record traceRecord
+	// seq is the position of the record in the replay, counting across loops
+	seq int64
+	// offset is the time of the record relative to the start of the replay
+	// (sec), counting across loops
+	offset float64
+}
+
+// traceStream decodes a trace file in a background goroutine and feeds the
+// records to workers through bounded channels. With more than one channel,
+// records are routed by client id so each channel sees whole client streams.
+// A worker which stops reading releases its channel, and the records routed
+// to it are dropped instead of blocking the other channels.
+type traceStream struct {
+	filePath   string
+	format     string
+	maxRecords int64
+	loop       bool
+
+	chans []chan traceItem
+	// released[i] is closed when the worker of chans[i] stops reading
+	released    []chan struct{}
+	releaseOnce []sync.Once
+	active      int32
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// newTraceStream starts decoding the trace. prefetch bounds the total number of
+// decoded records waiting in the channels.
+func newTraceStream(filePath string, format string, maxRecords int64, loop bool, numChans int, prefetch int) *traceStream {
+	perChan := prefetch / numChans
+	if perChan < 1 {
+		perChan = 1
+	}
+
+	s := &traceStream{
+		filePath:    filePath,
+		format:      format,
+		maxRecords:  maxRecords,
+		loop:        loop,
+		chans:       make([]chan traceItem, numChans),
+		released:    make([]chan struct{}, numChans),
+		releaseOnce: make([]sync.Once, numChans),
+		active:      int32(numChans),
+		done:        make(chan struct{}),
+	}
+	for i := range s.chans {
+		s.chans[i] = make(chan traceItem, perChan)
+		s.released[i] = make(chan struct{})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go s.run(ctx)
+	return s
+}
+
+func (s *traceStream) run(ctx context.Context) {
+	defer close(s.done)
+	defer func() {
+		for _, ch := range s.chans {
+			close(ch)
+		}
+	}()
+
+	var seq int64
+	var passOffset float64
+	for {
+		first, last, n, err := s.pass(ctx, &seq, passOffset)
+		if err != nil {
+			if err != context.Canceled {
+				s.err = err
+				fmt.Printf("trace stream stopped: %v\n", err)
+			}
+			return
+		}
+		if !s.loop || n == 0 {
+			return
+		}
+		// Timestamps have one-second resolution, so a pass lasts until the
+		// end of the second of its last record.
+		passOffset += last - first + 1
+	}
+}
+
+// pass replays the trace file once, returning the first and last timestamps
+// and the number of records sent.
+func (s *traceStream) pass(ctx context.Context, seq *int64, passOffset float64) (float64, float64, int64, error) {
+	reader, err := newTraceReader(s.filePath, s.format)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer reader.Close()
+
+	var first, last float64
+	var n int64
+	for s.maxRecords <= 0 || n < s.maxRecords {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		if n == 0 {
+			first = record.timestamp
+		}
+		last = record.timestamp
+
+		i := 0
+		if len(s.chans) > 1 {
+			h := fnv.New32a()
+			h.Write([]byte(record.clientID))
+			i = int(h.Sum32() % uint32(len(s.chans)))
+		}
+
+		item := traceItem{record: record, seq: *seq, offset: record.timestamp - first + passOffset}
+		select {
+		case s.chans[i] <- item:
+		case <-s.released[i]:
+			// Nobody reads the records of this client any more
+		case <-ctx.Done():
+			return 0, 0, 0, ctx.Err()
+		}
+		*seq++
+		n++
+	}
+	return first, last, n, nil
+}
+
+// next returns the next record from the given channel, or false when the
+// trace is exhausted or the context is done.
+func (s *traceStream) next(ctx context.Context, ch int) (traceItem, bool) {
+	select {
+	case item, ok := <-s.chans[ch]:
+		return item, ok
+	case <-ctx.Done():
+		return traceItem{}, false
+	}
+}
+
+// release tells the stream that the worker of the given channel stopped
+// reading. Once every channel is released the background reader stops.
+func (s *traceStream) release(ch int) {
+	s.releaseOnce[ch].Do(func() {
+		close(s.released[ch])
+		if atomic.AddInt32(&s.active, -1) == 0 {
+			s.cancel()
+		}
+	})
+}
+
+// stopped reports whether every channel was released.
+func (s *traceStream) stopped() bool {
+	return atomic.LoadInt32(&s.active) == 0
+}
+
+// Close stops the background reader and waits for it to exit.
+func (s *traceStream) Close() error {
+	s.cancel()
+	<-s.done
+	return s.err
+}
@@ -0,0 +1,189 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const testTrace = `0,keyA,4,10,1,get,0
+0,keyB,4,20,2,set,60
+1,keyA,4,30,1,set,0
+bad line
+2,keyA,4,30,2,set,0
+3,keyC,4,0,1,delete,0
+`
+
+func writeTestTrace(t *testing.T, name string) string {
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if filepath.Ext(name) == ".zst" {
+		enc, err := zstd.NewWriter(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer enc.Close()
+		_, err = enc.Write([]byte(testTrace))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	if _, err := f.WriteString(testTrace); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseTraceFile(t *testing.T) {
+	for _, name := range []string{"trace.csv", "trace.zst"} {
+		records, err := parseTraceFile(writeTestTrace(t, name), "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 5 {
+			t.Fatalf("%s: want 5 records, got %d", name, len(records))
+		}
+		if r := records[1]; r.key != "keyB" || r.valueSize != 20 || r.clientID != "2" || r.operation != "set" || r.ttl != 60 {
+			t.Errorf("%s: unexpected record %+v", name, r)
+		}
+	}
+
+	records, err := parseTraceFile(writeTestTrace(t, "trace.csv"), "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Errorf("want 2 records with maxrecords, got %d", len(records))
+	}
+}
+
+func TestScanTraceKeys(t *testing.T) {
+	s, err := scanTraceKeys(writeTestTrace(t, "trace.zst"), "", 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.records != 5 || len(s.keys) != 3 {
+		t.Fatalf("want 5 records and 3 keys, got %d and %d", s.records, len(s.keys))
+	}
+	if s.keys[0] != "keyA" || s.keys[1] != "keyB" || s.keys[2] != "keyC" {
+		t.Errorf("keys not in order of first appearance: %v", s.keys)
+	}
+	// keyA was seen with sizes 10, 30 and 30
+	if s.valueSizes["keyA"] != 30 {
+		t.Errorf("want median value size 30 for keyA, got %d", s.valueSizes["keyA"])
+	}
+	if s.reads != 1 || s.writes != 3 || s.deletes != 1 {
+		t.Errorf("unexpected op mix: reads=%d writes=%d deletes=%d", s.reads, s.writes, s.deletes)
+	}
+}
+
+func TestTraceStream(t *testing.T) {
+	path := writeTestTrace(t, "trace.csv")
+
+	s := newTraceStream(path, "", 0, false, 1, 2)
+	var keys []string
+	for {
+		item, ok := s.next(context.Background(), 0)
+		if !ok {
+			break
+		}
+		keys = append(keys, item.record.key)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 5 || keys[0] != "keyA" || keys[4] != "keyC" {
+		t.Errorf("unexpected streamed keys %v", keys)
+	}
+
+	// Looping streams keep going and shift the offsets of later passes
+	s = newTraceStream(path, "", 0, true, 1, 2)
+	var last traceItem
+	for i := 0; i < 6; i++ {
+		last, _ = s.next(context.Background(), 0)
+	}
+	s.Close()
+	if last.seq != 5 || last.offset != 4 {
+		t.Errorf("want seq 5 at offset 4 on the second pass, got seq %d offset %v", last.seq, last.offset)
+	}
+
+	// Partitioned streams keep each client on one channel
+	s = newTraceStream(path, "", 0, false, 2, 10)
+	defer s.Close()
+	for ch := 0; ch < 2; ch++ {
+		clients := make(map[string]bool)
+		for {
+			item, ok := s.next(context.Background(), ch)
+			if !ok {
+				break
+			}
+			clients[item.record.clientID] = true
+		}
+		if len(clients) > 1 {
+			t.Errorf("channel %d received several clients: %v", ch, clients)
+		}
+	}
+}
+
+func TestTraceStreamRelease(t *testing.T) {
+	// Two clients on different channels, with more records than fit in the
+	// channels
+	var trace strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&trace, "%d,key%d,4,10,1,get,0\n%d,key%d,4,10,2,get,0\n", i, i, i, i)
+	}
+	path := filepath.Join(t.TempDir(), "trace.csv")
+	if err := os.WriteFile(path, []byte(trace.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTraceStream(path, "", 0, false, 2, 4)
+	defer s.Close()
+	counts := make([]int, 2)
+	var wg sync.WaitGroup
+	for ch := 0; ch < 2; ch++ {
+		wg.Add(1)
+		go func(ch int) {
+			defer wg.Done()
+			for {
+				if ch == 0 && counts[ch] == 1 {
+					// This thread stops early
+					s.release(ch)
+					return
+				}
+				if _, ok := s.next(context.Background(), ch); !ok {
+					return
+				}
+				counts[ch]++
+			}
+		}(ch)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(10 * time.Second):
+		t.Fatal("stream blocked on the channel of the stopped thread")
+	}
+	if counts[0] != 1 || counts[1] != 100 {
+		t.Errorf("want 1 and 100 records read, got %v", counts)
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright 2024 Johan Edeland
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workload
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/pingcap/go-ycsb/pkg/util"
+)
+
+// TraceStatsOptions controls what CollectTraceStats computes.
+type TraceStatsOptions struct {
+	// InputFormat is the registered trace format of the file (empty = auto)
+	InputFormat string
+	// MaxRecords limits the number of records read (0 = unlimited), like
+	// trace.maxrecords
+	MaxRecords int64
+	// Window is the length of the working set windows (sec)
+	Window float64
+	// ReuseDistance enables the reuse distance distribution, which needs
+	// 4 bytes of memory per record
+	ReuseDistance bool
+}
+
+// TraceDistribution summarises the distribution of a trace metric.
+type TraceDistribution struct {
+	Count int64   `json:"count"`
+	Min   int64   `json:"min"`
+	Mean  float64 `json:"mean"`
+	P50   int64   `json:"p50"`
+	P90   int64   `json:"p90"`
+	P95   int64   `json:"p95"`
+	P99   int64   `json:"p99"`
+	P999  int64   `json:"p999"`
+	Max   int64   `json:"max"`
+}
+
+// TraceWindow is the working set of one window of the trace.
+type TraceWindow struct {
+	// Start is the offset of the window from the start of the trace (sec)
+	Start   float64 `json:"start"`
+	Records int64   `json:"records"`
+	// Keys is the number of distinct keys accessed in the window
+	Keys int64 `json:"keys"`
+	// Bytes is the total key and value size of those keys, using the largest
+	// size seen for each key in the window
+	Bytes int64 `json:"bytes"`
+	// NewKeys is the number of keys accessed for the first time
+	NewKeys int64 `json:"new_keys"`
+	// TotalKeys is the number of distinct keys seen up to the end of the window
+	TotalKeys int64 `json:"total_keys"`
+}
+
+// TraceClientStats is the activity of one client of the trace.
+type TraceClientStats struct {
+	Client     string           `json:"client"`
+	Records    int64            `json:"records"`
+	Operations map[string]int64 `json:"operations"`
+}
+
+// TraceStats characterises a trace as the trace workload sees it.
+type TraceStats struct {
+	File       string           `json:"file"`
+	Records    int64            `json:"records"`
+	UniqueKeys int64            `json:"unique_keys"`
+	Duration   float64          `json:"duration"`
+	Operations map[string]int64 `json:"operations"`
+
+	KeySize TraceDistribution `json:"key_size"`
+	// ValueSize only covers records with a non-zero value size
+	ValueSize TraceDistribution `json:"value_size"`
+	// InterArrival is the time between consecutive requests of the same
+	// client (ms)
+	InterArrival TraceDistribution `json:"inter_arrival_ms"`
+	// ReuseDistance is the number of distinct other keys accessed between two
+	// accesses to the same key. First accesses are not included.
+	ReuseDistance *TraceDistribution `json:"reuse_distance,omitempty"`
+
+	WorkingSet []TraceWindow       `json:"working_set"`
+	Clients    []*TraceClientStats `json:"clients"`
+}
+
+// fenwick is a Fenwick tree of access marks which grows one position at a
+// time, used to count distinct keys between two accesses.
+type fenwick []int32
+
+// push appends a position holding v.
+func (f *fenwick) push(v int32) {
+	t := *f
+	i := len(t) + 1
+	// The new node covers positions (i-lowbit(i), i]
+	sum := v
+	for j := i - 1; j > i-(i&-i); j -= j & -j {
+		sum += t[j-1]
+	}
+	*f = append(t, sum)
+}
+
+// add adds v at position i (0-based).
+func (f fenwick) add(i int, v int32) {
+	for i++; i <= len(f); i += i & -i {
+		f[i-1] += v
+	}
+}
+
+// sum returns the sum of positions [0, i).
+func (f fenwick) sum(i int) int64 {
+	var s int64
+	for ; i > 0; i -= i & -i {
+		s += int64(f[i-1])
+	}
+	return s
+}
+
+func newTraceHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(1, 1<<40, 3)
+}
+
+func newTraceDistribution(h *hdrhistogram.Histogram) TraceDistribution {
+	return TraceDistribution{
+		Count: h.TotalCount(),
+		Min:   h.Min(),
+		Mean:  h.Mean(),
+		P50:   h.ValueAtQuantile(50),
+		P90:   h.ValueAtQuantile(90),
+		P95:   h.ValueAtQuantile(95),
+		P99:   h.ValueAtQuantile(99),
+		P999:  h.ValueAtQuantile(99.9),
+		Max:   h.Max(),
+	}
+}
+
+// traceWindowState accumulates the current working set window.
+type traceWindowState struct {
+	TraceWindow
+	sizes map[string]int64 // largest key plus value size per key
+}
+
+// CollectTraceStats makes one pass over a trace file, decoding it with the
+// same reader as the trace workload.
+func CollectTraceStats(filePath string, opts TraceStatsOptions) (*TraceStats, error) {
+	reader, err := newTraceReader(filePath, opts.InputFormat)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	if opts.Window <= 0 {
+		opts.Window = 60
+	}
+
+	stats := &TraceStats{
+		File:       filePath,
+		Operations: make(map[string]int64),
+	}
+	keySizes := newTraceHistogram()
+	valueSizes := newTraceHistogram()
+	interArrival := newTraceHistogram()
+	var reuse *hdrhistogram.Histogram
+	var marks fenwick
+	if opts.ReuseDistance {
+		reuse = newTraceHistogram()
+	}
+
+	// Position of the last access of every key
+	lastAccess := make(map[string]int64)
+	clients := make(map[string]*TraceClientStats)
+	lastClientTime := make(map[string]float64)
+
+	var start float64
+	var window *traceWindowState
+	closeWindow := func() {
+		for _, size := range window.sizes {
+			window.Bytes += size
+		}
+		window.Keys = int64(len(window.sizes))
+		window.TotalKeys = int64(len(lastAccess))
+		stats.WorkingSet = append(stats.WorkingSet, window.TraceWindow)
+	}
+
+	for opts.MaxRecords <= 0 || stats.Records < opts.MaxRecords {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		pos := stats.Records
+		stats.Records++
+		if pos == 0 {
+			start = record.timestamp
+		}
+		offset := record.timestamp - start
+		if offset > stats.Duration {
+			stats.Duration = offset
+		}
+
+		// Windows are aligned to the start of the trace, empty ones are skipped
+		windowStart := float64(int64(offset/opts.Window)) * opts.Window
+		if window == nil || windowStart != window.Start {
+			if window != nil {
+				closeWindow()
+			}
+			window = &traceWindowState{
+				TraceWindow: TraceWindow{Start: windowStart},
+				sizes:       make(map[string]int64),
+			}
+		}
+		window.Records++
+
+		stats.Operations[record.operation]++
+		keySizes.RecordValue(int64(record.keySize))
+		if record.valueSize > 0 {
+			valueSizes.RecordValue(int64(record.valueSize))
+		}
+		if size := int64(record.keySize + record.valueSize); size > window.sizes[record.key] {
+			window.sizes[record.key] = size
+		} else if _, ok := window.sizes[record.key]; !ok {
+			window.sizes[record.key] = size
+		}
+
+		client, ok := clients[record.clientID]
+		if !ok {
+			client = &TraceClientStats{Client: record.clientID, Operations: make(map[string]int64)}
+			clients[record.clientID] = client
+		} else {
+			gap := (record.timestamp - lastClientTime[record.clientID]) * 1000
+			if gap >= 0 {
+				interArrival.RecordValue(int64(gap))
+			}
+		}
+		lastClientTime[record.clientID] = record.timestamp
+		client.Records++
+		client.Operations[record.operation]++
+
+		last, seen := lastAccess[record.key]
+		if !seen {
+			window.NewKeys++
+		}
+		lastAccess[record.key] = pos
+		if reuse != nil {
+			// Every key has a mark at its last access, so the marks after the
+			// previous access of this key count the distinct keys in between.
+			marks.push(1)
+			if seen {
+				reuse.RecordValue(marks.sum(int(pos)) - marks.sum(int(last)+1))
+				marks.add(int(last), -1)
+			}
+		}
+	}
+	if window != nil {
+		closeWindow()
+	}
+
+	stats.UniqueKeys = int64(len(lastAccess))
+	stats.KeySize = newTraceDistribution(keySizes)
+	stats.ValueSize = newTraceDistribution(valueSizes)
+	stats.InterArrival = newTraceDistribution(interArrival)
+	if reuse != nil {
+		d := newTraceDistribution(reuse)
+		stats.ReuseDistance = &d
+	}
+
+	stats.Clients = make([]*TraceClientStats, 0, len(clients))
+	for _, c := range clients {
+		stats.Clients = append(stats.Clients, c)
+	}
+	sort.Slice(stats.Clients, func(i, j int) bool {
+		if stats.Clients[i].Records != stats.Clients[j].Records {
+			return stats.Clients[i].Records > stats.Clients[j].Records
+		}
+		return stats.Clients[i].Client < stats.Clients[j].Client
+	})
+	return stats, nil
+}
+
+// sortedOperations returns the operations of ops by decreasing count.
+func sortedOperations(ops map[string]int64) []string {
+	names := make([]string, 0, len(ops))
+	for op := range ops {
+		names = append(names, op)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if ops[names[i]] != ops[names[j]] {
+			return ops[names[i]] > ops[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func distributionRow(name string, d TraceDistribution) []string {
+	return []string{name, util.IntToString(d.Count), util.IntToString(d.Min), util.FloatToOneString(d.Mean),
+		util.IntToString(d.P50), util.IntToString(d.P90), util.IntToString(d.P95),
+		util.IntToString(d.P99), util.IntToString(d.P999), util.IntToString(d.Max)}
+}
+
+// WriteText prints the stats as tables. Only the topClients busiest clients
+// are listed (0 = all).
+func (s *TraceStats) WriteText(w io.Writer, topClients int) {
+	fmt.Fprintf(w, "Trace: %s\n", s.File)
+	fmt.Fprintf(w, "Records: %d, unique keys: %d, clients: %d, duration: %.0fs\n\n",
+		s.Records, s.UniqueKeys, len(s.Clients), s.Duration)
+
+	var ops [][]string
+	for _, op := range sortedOperations(s.Operations) {
+		n := s.Operations[op]
+		ops = append(ops, []string{op, util.IntToString(n), fmt.Sprintf("%.2f", float64(n)*100/float64(s.Records))})
+	}
+	util.RenderTable(w, []string{"Operation", "Count", "Percent"}, ops)
+	fmt.Fprintln(w)
+
+	dists := [][]string{
+		distributionRow("key size (B)", s.KeySize),
+		distributionRow("value size (B)", s.ValueSize),
+		distributionRow("inter-arrival (ms)", s.InterArrival),
+	}
+	if s.ReuseDistance != nil {
+		dists = append(dists, distributionRow("reuse distance", *s.ReuseDistance))
+	}
+	util.RenderTable(w, []string{"Metric", "Count", "Min", "Mean", "P50", "P90", "P95", "P99", "P99.9", "Max"}, dists)
+	fmt.Fprintln(w)
+
+	var windows [][]string
+	for _, win := range s.WorkingSet {
+		windows = append(windows, []string{util.FloatToOneString(win.Start), util.IntToString(win.Records),
+			util.IntToString(win.Keys), util.IntToString(win.Bytes), util.IntToString(win.NewKeys),
+			util.IntToString(win.TotalKeys)})
+	}
+	util.RenderTable(w, []string{"Window start (s)", "Records", "Keys", "Bytes", "New keys", "Total keys"}, windows)
+	fmt.Fprintln(w)
+
+	var clients [][]string
+	for i, c := range s.Clients {
+		if topClients > 0 && i >= topClients {
+			break
+		}
+		var mix []string
+		for _, op := range sortedOperations(c.Operations) {
+			mix = append(mix, fmt.Sprintf("%s=%d", op, c.Operations[op]))
+		}
+		clients = append(clients, []string{c.Client, util.IntToString(c.Records),
+			fmt.Sprintf("%.2f", float64(c.Records)*100/float64(s.Records)), strings.Join(mix, " ")})
+	}
+	util.RenderTable(w, []string{"Client", "Records", "Percent", "Operations"}, clients)
+	if topClients > 0 && len(s.Clients) > topClients {
+		fmt.Fprintf(w, "(%d more clients)\n", len(s.Clients)-topClients)
+	}
+}
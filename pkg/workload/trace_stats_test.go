@@ -0,0 +1,34 @@
+package workload
+
+import (
+	"testing"
+)
+
+func TestCollectTraceStats(t *testing.T) {
+	stats, err := CollectTraceStats(writeTestTrace(t, "trace.zst"), TraceStatsOptions{Window: 2, ReuseDistance: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Records != 5 || stats.UniqueKeys != 3 || len(stats.Clients) != 2 {
+		t.Fatalf("unexpected totals %+v", stats)
+	}
+	if stats.Operations["set"] != 3 || stats.Operations["get"] != 1 || stats.Operations["delete"] != 1 {
+		t.Errorf("unexpected op mix %v", stats.Operations)
+	}
+
+	// keyA is accessed at positions 0, 2 and 3 with keyB in between once
+	if d := stats.ReuseDistance; d == nil || d.Count != 2 || d.Min != 0 || d.Max != 1 {
+		t.Errorf("unexpected reuse distance %+v", d)
+	}
+
+	// Windows [0, 2) and [2, 4)
+	if len(stats.WorkingSet) != 2 {
+		t.Fatalf("want 2 windows, got %+v", stats.WorkingSet)
+	}
+	if w := stats.WorkingSet[0]; w.Records != 3 || w.Keys != 2 || w.NewKeys != 2 || w.Bytes != 34+24 {
+		t.Errorf("unexpected first window %+v", w)
+	}
+	if w := stats.WorkingSet[1]; w.Records != 2 || w.Keys != 2 || w.NewKeys != 1 || w.TotalKeys != 3 {
+		t.Errorf("unexpected second window %+v", w)
+	}
+}
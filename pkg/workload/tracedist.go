@@ -49,6 +49,10 @@ const (
 	TraceDistMaxRecords        = "tracedist.maxrecords"
 	TraceDistMaxRecordsDefault = int64(0) // 0 = unlimited
 
+	// TraceDistFormat is the trace file format, like trace.format
+	TraceDistFormat        = "tracedist.format"
+	TraceDistFormatDefault = TraceFormatAuto
+
 	// TraceDistTable is the table name for operations
 	TraceDistTable        = "tracedist.table"
 	TraceDistTableDefault = "usertable"
@@ -222,7 +226,7 @@ func (traceDistCreator) Create(p *properties.Properties) (ycsb.Workload, error)
 	maxRecords := p.GetInt64(TraceDistMaxRecords, TraceDistMaxRecordsDefault)
 
 	fmt.Printf("Loading trace file for distribution: %s\n", traceFile)
-	records, err := parseTraceFile(traceFile, maxRecords)
+	records, err := parseTraceFile(traceFile, p.GetString(TraceDistFormat, TraceDistFormatDefault), maxRecords)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse trace file: %w", err)
 	}
@@ -27,30 +27,36 @@
 package workload
 
 import (
-	"bufio"
 	"context"
-	"encoding/csv"
 	"fmt"
 	"hash/fnv"
-	"io"
-	"os"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
-	"github.com/klauspost/compress/zstd"
 	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/measurement"
 	"github.com/pingcap/go-ycsb/pkg/prop"
 	"github.com/pingcap/go-ycsb/pkg/ycsb"
 )
 
 // Property keys for trace workload configuration
 const (
-	// TraceFile is the path to the trace file (can be .zst compressed or plain CSV)
+	// TraceFile is the path to the trace file (can be .zst compressed)
 	TraceFile        = "trace.file"
 	TraceFileDefault = ""
 
+	// TraceFormat is the name of the trace file format, see RegisterTraceFormat:
+	//   - "auto": the binary format of trace-convert, otherwise twemcache (default)
+	//   - "twemcache": timestamp, key, key_size, value_size, client_id, operation, ttl
+	//   - "binary": the compact binary format written by trace-convert
+	//   - "jsonl": one JSON object per line with the same fields
+	//   - "oraclegeneral": the libCacheSim oracleGeneral binary format
+	TraceFileFormat        = "trace.format"
+	TraceFileFormatDefault = TraceFormatAuto
+
 	// TraceTable is the table name to use for operations
 	TraceTable        = "trace.table"
 	TraceTableDefault = "usertable"
@@ -83,6 +89,55 @@ const (
 	// Value content is derived deterministically from the key via hashing.
 	TraceDeterministicValues        = "trace.deterministicvalues"
 	TraceDeterministicValuesDefault = false
+
+	// TraceReplayMode determines when records are issued:
+	//   - "closed": workers pull the next record as soon as they are free (default)
+	//   - "timestamp": each record is issued at its original trace time, scaled by
+	//     trace.speedup. Latency from the scheduled time is reported as
+	//     INTENDED_<op>, so queueing delay caused by busy workers shows up in
+	//     the results (see measurement.latency).
+	TraceReplayMode        = "trace.replaymode"
+	TraceReplayModeDefault = "closed"
+
+	// TraceSpeedup divides the gaps between trace timestamps in timestamp mode
+	// (e.g. 2.0 replays the trace twice as fast as it was recorded)
+	TraceSpeedup        = "trace.speedup"
+	TraceSpeedupDefault = float64(1.0)
+
+	// TracePartition determines how records are distributed across threads:
+	//   - "none": all threads pull from one shared cursor (default)
+	//   - "client": records are partitioned by hashing the client id into
+	//     threadcount buckets, so each thread replays whole client streams in order
+	TracePartition        = "trace.partition"
+	TracePartitionDefault = "none"
+
+	// TraceStreaming decodes the trace incrementally during the run instead of
+	// loading every record into memory. The load phase and deterministic values
+	// mode use a separate pre-scan of the trace to compute the key set.
+	TraceStreaming        = "trace.streaming"
+	TraceStreamingDefault = false
+
+	// TracePrefetch is the maximum number of decoded records buffered ahead of
+	// the workers in streaming mode
+	TracePrefetch        = "trace.prefetch"
+	TracePrefetchDefault = 65536
+
+	// TraceHonorTTL writes records with the TTL from the trace when the
+	// database supports expiring writes (see ycsb.TTLDB)
+	TraceHonorTTL        = "trace.ttl"
+	TraceHonorTTLDefault = true
+)
+
+// Trace partitioning modes
+const (
+	tracePartitionNone   = "none"
+	tracePartitionClient = "client"
+)
+
+// Trace replay modes
+const (
+	traceReplayClosed    = "closed"
+	traceReplayTimestamp = "timestamp"
 )
 
 // traceRecord represents a single record from the trace file
@@ -105,31 +160,76 @@ type traceWorkload struct {
 	loopReplay     bool
 	readMode       string // "read", "skip", or "write"
 	writeValueSize int    // value size when converting reads to writes
+	honorTTL       bool   // pass trace TTLs through to the database
 
 	// Deterministic values mode: all writes to the same key use identical content
 	deterministicValues bool
 	// Pre-computed deterministic values per key (only populated when deterministicValues=true)
 	deterministicCache map[string][]byte
 
-	// Trace data - loaded into memory for fast access
+	// Streaming mode: records are decoded in the background during the run
+	traceFile   string
+	traceFormat string
+	maxRecords  int64
+	streaming   bool
+	prefetch    int
+	stream      *traceStream
+
+	// Trace data - loaded into memory for fast access (not used in streaming mode)
 	records    []traceRecord
 	recordIdx  int64 // atomic counter for round-robin access
 	numRecords int64
 
+	// Timestamp replay mode: records are scheduled relative to replayStart
+	replayMode      string
+	speedup         float64
+	traceStart      float64 // earliest timestamp in the trace (sec)
+	traceSpan       float64 // duration of one pass over the trace (sec)
+	replayStart     time.Time
+	replayStartOnce sync.Once
+
+	// Client partitioning: partitions[i] holds the indexes of the records
+	// replayed by thread i, in trace order
+	partitionMode string
+	partitions    [][]int
+
+	// threadMu guards the stream and the partitions, which are rebuilt when a
+	// phase of a schedule runs with another thread count
+	threadMu sync.Mutex
+
 	// For load phase - unique keys that need to be inserted
 	uniqueKeys    []string
 	keySizes      map[string]int
 	valueSizes    map[string]int
 	loadIdx       int64 // atomic counter for load phase
 	numUniqueKeys int64
+
+	// Keys which the trace increments or decrements. They are written as
+	// decimal counters instead of filler bytes.
+	counterKeys map[string]struct{}
+	// Without a pre-scan, counter keys are learned from the incr and decr
+	// records of the run
+	learnCounters   bool
+	learnedCounters sync.Map
 }
 
 // traceState holds per-thread state
 type traceState struct {
 	// Buffer for building values
 	valueBuf []byte
+
+	// Records of this thread's partition and the replay cursor into them
+	// (only used when trace.partition is "client")
+	partition []int
+	cursor    int64
+
+	// Stream channel this thread reads from (only used in streaming mode)
+	channel int
 }
 
+// counterInitValue is written to counter keys in place of filler bytes
+var counterInitValue = []byte("0")
+
 type traceContextKey string
 
 const traceStateKey = traceContextKey("trace")
@@ -177,93 +277,58 @@ func medianInt(vals []int) int {
 	return (vals[n/2-1] + vals[n/2]) / 2
 }
 
-// parseTraceFile reads and parses a trace file (supports .zst compression)
-func parseTraceFile(filePath string, maxRecords int64) ([]traceRecord, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open trace file: %w", err)
+// InitThread implements the Workload InitThread interface
+func (w *traceWorkload) InitThread(ctx context.Context, threadID int, threadCount int) context.Context {
+	state := &traceState{
+		valueBuf: make([]byte, 0, 4096), // Pre-allocate buffer
 	}
-	defer file.Close()
-
-	var reader io.Reader = file
-
-	// Check if file is zstd compressed
-	if strings.HasSuffix(filePath, ".zst") || strings.HasSuffix(filePath, ".zstd") {
-		decoder, err := zstd.NewReader(file)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
-		}
-		defer decoder.Close()
-		reader = decoder
+	if !w.p.GetBool(prop.DoTransactions, true) {
+		return context.WithValue(ctx, traceStateKey, state)
 	}
 
-	// Use buffered reader for better performance
-	bufReader := bufio.NewReaderSize(reader, 64*1024)
-	csvReader := csv.NewReader(bufReader)
-
-	var records []traceRecord
-	lineNum := 0
-
-	for {
-		if maxRecords > 0 && int64(len(records)) >= maxRecords {
-			break
+	switch {
+	case w.streaming:
+		numChans := 1
+		if w.partitionMode == tracePartitionClient {
+			numChans = threadCount
+			state.channel = threadID
 		}
-
-		record, err := csvReader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			// Skip malformed lines
-			lineNum++
-			continue
+		w.threadMu.Lock()
+		// A partitioned stream is stopped once every thread of the previous
+		// phase released its channel
+		if w.stream == nil || len(w.stream.chans) != numChans || w.stream.stopped() {
+			if w.stream != nil {
+				w.stream.Close()
+			}
+			w.stream = newTraceStream(w.traceFile, w.traceFormat, w.maxRecords, w.loopReplay, numChans, w.prefetch)
 		}
-
-		lineNum++
-
-		// Skip if not enough fields
-		if len(record) < 7 {
-			continue
+		w.threadMu.Unlock()
+	case w.partitionMode == tracePartitionClient:
+		w.threadMu.Lock()
+		if len(w.partitions) != threadCount {
+			w.buildPartitions(threadCount)
 		}
-
-		// Parse fields: timestamp, key, key_size, value_size, client_id, op, ttl
-		timestamp, _ := strconv.ParseFloat(record[0], 64)
-		key := record[1]
-		keySize, _ := strconv.Atoi(record[2])
-		valueSize, _ := strconv.Atoi(record[3])
-		clientID := record[4]
-		op := strings.ToLower(record[5])
-		ttl, _ := strconv.Atoi(record[6])
-
-		records = append(records, traceRecord{
-			timestamp: timestamp,
-			key:       key,
-			keySize:   keySize,
-			valueSize: valueSize,
-			clientID:  clientID,
-			operation: op,
-			ttl:       ttl,
-		})
-	}
-
-	return records, nil
-}
-
-// InitThread implements the Workload InitThread interface
-func (w *traceWorkload) InitThread(ctx context.Context, threadID int, threadCount int) context.Context {
-	state := &traceState{
-		valueBuf: make([]byte, 0, 4096), // Pre-allocate buffer
+		state.partition = w.partitions[threadID]
+		w.threadMu.Unlock()
 	}
 	return context.WithValue(ctx, traceStateKey, state)
 }
 
 // CleanupThread implements the Workload CleanupThread interface
 func (w *traceWorkload) CleanupThread(ctx context.Context) {
-	// Nothing to clean up
+	// Let the stream drop the records of a thread which stopped early, so it
+	// doesn't block the other threads
+	state, ok := ctx.Value(traceStateKey).(*traceState)
+	if ok && w.stream != nil && w.partitionMode == tracePartitionClient {
+		w.stream.release(state.channel)
+	}
 }
 
 // Close implements the Workload Close interface
 func (w *traceWorkload) Close() error {
+	if w.stream != nil {
+		return w.stream.Close()
+	}
 	return nil
 }
 
@@ -273,6 +338,51 @@ func (w *traceWorkload) Load(ctx context.Context, db ycsb.DB, totalCount int64)
 	return nil
 }
 
+// loadValue returns the value a key is loaded with. Keys used as counters
+// in the trace start out as 0 so that native increments succeed.
+func (w *traceWorkload) loadValue(key string) []byte {
+	if w.isCounter(key) {
+		return counterInitValue
+	}
+	if w.deterministicValues {
+		return w.deterministicCache[key]
+	}
+	valueSize := w.valueSizes[key]
+	if valueSize <= 0 {
+		valueSize = 100
+	}
+	value := make([]byte, valueSize)
+	for i := range value {
+		value[i] = 'x'
+	}
+	return value
+}
+
+// isCounter reports whether the trace increments or decrements the key.
+func (w *traceWorkload) isCounter(key string) bool {
+	if _, ok := w.counterKeys[key]; ok {
+		return true
+	}
+	if w.learnCounters {
+		_, ok := w.learnedCounters.Load(key)
+		return ok
+	}
+	return false
+}
+
+// initCounter writes 0 to a counter key the first time the run increments
+// or decrements it, when counter keys weren't known to the load phase. The
+// key may hold filler bytes, which can't be incremented.
+func (w *traceWorkload) initCounter(ctx context.Context, db ycsb.DB, key string) error {
+	if !w.learnCounters || w.isCounter(key) {
+		return nil
+	}
+	if _, loaded := w.learnedCounters.LoadOrStore(key, struct{}{}); loaded {
+		return nil
+	}
+	return db.Update(ctx, w.table, key, map[string][]byte{w.fieldName: counterInitValue})
+}
+
 // DoInsert implements the Workload DoInsert interface
 // Used during the load phase to insert unique keys
 func (w *traceWorkload) DoInsert(ctx context.Context, db ycsb.DB) error {
@@ -282,24 +392,7 @@ func (w *traceWorkload) DoInsert(ctx context.Context, db ycsb.DB) error {
 	}
 
 	key := w.uniqueKeys[idx]
-
-	var value []byte
-	if w.deterministicValues {
-		value = w.deterministicCache[key]
-	} else {
-		valueSize := w.valueSizes[key]
-		if valueSize <= 0 {
-			valueSize = 100
-		}
-		value = make([]byte, valueSize)
-		for i := range value {
-			value[i] = 'x'
-		}
-	}
-
-	if idx < 10 {
-		fmt.Printf("[TRACE DEBUG] DoInsert: key=%s valueSize=%d\n", key, len(value))
-	}
+	value := w.loadValue(key)
 
 	values := map[string][]byte{w.fieldName: value}
 	return db.Insert(ctx, w.table, key, values)
@@ -328,23 +421,8 @@ func (w *traceWorkload) DoBatchInsert(ctx context.Context, batchSize int, db ycs
 		}
 
 		key := w.uniqueKeys[idx]
-
-		var value []byte
-		if w.deterministicValues {
-			value = w.deterministicCache[key]
-		} else {
-			valueSize := w.valueSizes[key]
-			if valueSize <= 0 {
-				valueSize = 100
-			}
-			value = make([]byte, valueSize)
-			for j := range value {
-				value[j] = 'x'
-			}
-		}
-
 		keys = append(keys, key)
-		values = append(values, map[string][]byte{w.fieldName: value})
+		values = append(values, map[string][]byte{w.fieldName: w.loadValue(key)})
 	}
 
 	if len(keys) == 0 {
@@ -354,23 +432,134 @@ func (w *traceWorkload) DoBatchInsert(ctx context.Context, batchSize int, db ycs
 	return batchDB.BatchInsert(ctx, w.table, keys, values)
 }
 
+// buildPartitions assigns every record to a thread by hashing its client id,
+// keeping the original trace order within each partition.
+func (w *traceWorkload) buildPartitions(threadCount int) {
+	w.partitions = make([][]int, threadCount)
+	clients := make(map[string]int)
+	for i := range w.records {
+		bucket, ok := clients[w.records[i].clientID]
+		if !ok {
+			h := fnv.New32a()
+			h.Write([]byte(w.records[i].clientID))
+			bucket = int(h.Sum32() % uint32(threadCount))
+			clients[w.records[i].clientID] = bucket
+		}
+		w.partitions[bucket] = append(w.partitions[bucket], i)
+	}
+
+	minSize, maxSize := len(w.records), 0
+	for _, part := range w.partitions {
+		if len(part) < minSize {
+			minSize = len(part)
+		}
+		if len(part) > maxSize {
+			maxSize = len(part)
+		}
+	}
+	fmt.Printf("Partitioned %d clients across %d threads (records per thread: min=%d, max=%d)\n",
+		len(clients), threadCount, minSize, maxSize)
+}
+
+// nextRecord returns the next record to replay and its time relative to the
+// start of the replay (sec), which keeps growing across loops of the trace.
+func (w *traceWorkload) nextRecord(ctx context.Context) (*traceRecord, float64, bool) {
+	if w.streaming {
+		state := ctx.Value(traceStateKey).(*traceState)
+		item, ok := w.stream.next(ctx, state.channel)
+		if !ok {
+			return nil, 0, false // No more records
+		}
+		return &item.record, item.offset, true
+	}
+
+	var idx, n int64
+	var record *traceRecord
+	if w.partitionMode == tracePartitionClient {
+		state := ctx.Value(traceStateKey).(*traceState)
+		idx, n = state.cursor, int64(len(state.partition))
+		if n == 0 || (idx >= n && !w.loopReplay) {
+			return nil, 0, false // No more records for this thread
+		}
+		state.cursor++
+		record = &w.records[state.partition[idx%n]]
+	} else {
+		idx, n = atomic.AddInt64(&w.recordIdx, 1)-1, w.numRecords
+		if idx >= n && !w.loopReplay {
+			return nil, 0, false // No more records
+		}
+		record = &w.records[idx%n]
+	}
+
+	offset := record.timestamp - w.traceStart + float64(idx/n)*w.traceSpan
+	return record, offset, true
+}
+
+// scheduledTime returns the wall-clock time at which a record with the given
+// offset should be issued in timestamp replay mode.
+func (w *traceWorkload) scheduledTime(offset float64) time.Time {
+	w.replayStartOnce.Do(func() {
+		w.replayStart = time.Now()
+	})
+	return w.replayStart.Add(time.Duration(offset / w.speedup * float64(time.Second)))
+}
+
+// waitForSchedule blocks until the scheduled time of a record and returns a
+// context carrying that time, so that the latency from it, including any
+// queueing delay, is measured as INTENDED_<op>.
+func (w *traceWorkload) waitForSchedule(ctx context.Context, offset float64) (context.Context, bool) {
+	at := w.scheduledTime(offset)
+	if d := time.Until(at); d > 0 {
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx, false
+		case <-timer.C:
+		}
+	}
+	return measurement.WithScheduledStart(ctx, at), true
+}
+
+// recordTTL returns the expiry of a write record, or 0 if it doesn't expire.
+// In timestamp replay mode the TTL is scaled by the speed-up factor so that
+// keys expire at the same point of the trace.
+func (w *traceWorkload) recordTTL(record *traceRecord) time.Duration {
+	if !w.honorTTL || record.ttl <= 0 {
+		return 0
+	}
+	ttl := time.Duration(record.ttl) * time.Second
+	if w.replayMode == traceReplayTimestamp {
+		ttl = time.Duration(float64(ttl) / w.speedup)
+	}
+	return ttl
+}
+
+// compareAndSwap replays a cas record. Like a memcached client doing gets
+// before cas, it first reads the current value (measured as READ) and then
+// swaps it for value, which fails if another thread wrote the key in between.
+func (w *traceWorkload) compareAndSwap(ctx context.Context, db ycsb.DB, casDB ycsb.CompareAndSwapDB, key string, value []byte) error {
+	row, err := db.Read(ctx, w.table, key, []string{w.fieldName})
+	if err != nil {
+		return err
+	}
+	_, err = casDB.CompareAndSwap(ctx, w.table, key, w.fieldName, row[w.fieldName], value)
+	return err
+}
+
 // DoTransaction implements the Workload DoTransaction interface
 // This replays the next operation from the trace
 func (w *traceWorkload) DoTransaction(ctx context.Context, db ycsb.DB) error {
-	// Get next record using atomic counter (round-robin across threads)
-	idx := atomic.AddInt64(&w.recordIdx, 1) - 1
-
-	if idx >= w.numRecords {
-		if w.loopReplay {
-			// Reset to beginning
-			atomic.StoreInt64(&w.recordIdx, 1)
-			idx = 0
-		} else {
-			return nil // No more records
-		}
+	record, offset, ok := w.nextRecord(ctx)
+	if !ok {
+		return nil
 	}
 
-	record := w.records[idx]
+	if w.replayMode == traceReplayTimestamp {
+		if ctx, ok = w.waitForSchedule(ctx, offset); !ok {
+			return nil
+		}
+	}
 
 	switch record.operation {
 	case "get", "gets":
@@ -425,13 +614,28 @@ func (w *traceWorkload) DoTransaction(ctx context.Context, db ycsb.DB) error {
 			}
 		}
 
-		if idx < 10 {
-			fmt.Printf("[TRACE DEBUG] DoTransaction: op=%s key=%s valueSize=%d\n",
-				record.operation, record.key, len(value))
+		if w.isCounter(record.key) {
+			value = counterInitValue
+		}
+
+
+		if record.operation == "cas" {
+			if casDB, ok := db.(ycsb.CompareAndSwapDB); ok {
+				return w.compareAndSwap(ctx, db, casDB, record.key, value)
+			}
 		}
 
 		values := map[string][]byte{w.fieldName: value}
 
+		if ttl := w.recordTTL(record); ttl > 0 {
+			if ttlDB, ok := db.(ycsb.TTLDB); ok {
+				if record.operation == "add" {
+					return ttlDB.InsertWithTTL(ctx, w.table, record.key, values, ttl)
+				}
+				return ttlDB.UpdateWithTTL(ctx, w.table, record.key, values, ttl)
+			}
+		}
+
 		if record.operation == "add" {
 			return db.Insert(ctx, w.table, record.key, values)
 		}
@@ -440,6 +644,15 @@ func (w *traceWorkload) DoTransaction(ctx context.Context, db ycsb.DB) error {
 	case "delete":
 		return db.Delete(ctx, w.table, record.key)
 
+	case "scan":
+		// Scans are recorded by recordtrace with the count as the value size
+		count := record.valueSize
+		if count <= 0 {
+			count = 1
+		}
+		_, err := db.Scan(ctx, w.table, record.key, count, []string{w.fieldName})
+		return err
+
 	case "append", "prepend":
 		var value []byte
 		if w.deterministicValues {
@@ -454,14 +667,28 @@ func (w *traceWorkload) DoTransaction(ctx context.Context, db ycsb.DB) error {
 				value[i] = 'x'
 			}
 		}
-		if idx < 10 {
-			fmt.Printf("[TRACE DEBUG] DoTransaction: op=%s key=%s valueSize=%d\n",
-				record.operation, record.key, len(value))
+		if appendDB, ok := db.(ycsb.AppendDB); ok {
+			if record.operation == "prepend" {
+				return appendDB.Prepend(ctx, w.table, record.key, w.fieldName, value)
+			}
+			return appendDB.Append(ctx, w.table, record.key, w.fieldName, value)
 		}
 		values := map[string][]byte{w.fieldName: value}
 		return db.Update(ctx, w.table, record.key, values)
 
 	case "incr", "decr":
+		if err := w.initCounter(ctx, db, record.key); err != nil {
+			return err
+		}
+		if counterDB, ok := db.(ycsb.AtomicCounterDB); ok {
+			delta := int64(1)
+			if record.operation == "decr" {
+				delta = -1
+			}
+			_, err := counterDB.Increment(ctx, w.table, record.key, w.fieldName, delta)
+			return err
+		}
+
 		// Treat as read-modify-write (read then update)
 		_, err := db.Read(ctx, w.table, record.key, []string{w.fieldName})
 		if err != nil {
@@ -504,82 +731,63 @@ func (traceWorkloadCreator) Create(p *properties.Properties) (ycsb.Workload, err
 		return nil, fmt.Errorf("trace.file property is required for trace workload")
 	}
 
-	maxRecords := p.GetInt64(TraceMaxRecords, TraceMaxRecordsDefault)
-
-	fmt.Printf("Loading trace file: %s\n", traceFile)
-	records, err := parseTraceFile(traceFile, maxRecords)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse trace file: %w", err)
-	}
-
-	if len(records) == 0 {
-		return nil, fmt.Errorf("no records found in trace file")
+	traceFormat := p.GetString(TraceFileFormat, TraceFileFormatDefault)
+	if err := checkTraceFormat(traceFormat); err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("Loaded %d records from trace\n", len(records))
-
+	maxRecords := p.GetInt64(TraceMaxRecords, TraceMaxRecordsDefault)
 	deterministicValues := p.GetBool(TraceDeterministicValues, TraceDeterministicValuesDefault)
+	streaming := p.GetBool(TraceStreaming, TraceStreamingDefault)
+	doTransactions := p.GetBool(prop.DoTransactions, true)
 
-	// Extract unique keys and their sizes for the load phase
-	uniqueKeysMap := make(map[string]bool)
-	keySizes := make(map[string]int)
-	valueSizes := make(map[string]int)
-
-	// Collect all non-zero value sizes per key for median computation
-	var allValueSizes map[string][]int
-	if deterministicValues {
-		allValueSizes = make(map[string][]int)
-	}
+	// Extract unique keys and their sizes for the load phase. In deterministic
+	// mode value sizes are replaced with per-key medians.
+	var records []traceRecord
+	var keySet *traceKeySet
+	if !streaming {
+		fmt.Printf("Loading trace file: %s\n", traceFile)
+		var err error
+		records, err = parseTraceFile(traceFile, traceFormat, maxRecords)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse trace file: %w", err)
+		}
 
-	for _, r := range records {
-		if !uniqueKeysMap[r.key] {
-			uniqueKeysMap[r.key] = true
-			keySizes[r.key] = r.keySize
+		if len(records) == 0 {
+			return nil, fmt.Errorf("no records found in trace file")
 		}
-		// Keep track of the largest value size for each key
-		if r.valueSize > valueSizes[r.key] {
-			valueSizes[r.key] = r.valueSize
+
+		fmt.Printf("Loaded %d records from trace\n", len(records))
+
+		keySet = newTraceKeySet(deterministicValues)
+		for i := range records {
+			keySet.add(&records[i])
 		}
-		if deterministicValues && r.valueSize > 0 {
-			allValueSizes[r.key] = append(allValueSizes[r.key], r.valueSize)
+		keySet.finish()
+	} else if !doTransactions || deterministicValues {
+		// The records are streamed during the run, but the load phase and
+		// deterministic values still need the key set from a pre-scan.
+		fmt.Printf("Scanning trace file: %s\n", traceFile)
+		var err error
+		keySet, err = scanTraceKeys(traceFile, traceFormat, maxRecords, deterministicValues)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trace file: %w", err)
 		}
-	}
 
-	// In deterministic mode, replace valueSizes with per-key medians
-	if deterministicValues {
-		for key, sizes := range allValueSizes {
-			valueSizes[key] = medianInt(sizes)
+		if keySet.records == 0 {
+			return nil, fmt.Errorf("no records found in trace file")
 		}
-	}
 
-	uniqueKeys := make([]string, 0, len(uniqueKeysMap))
-	for k := range uniqueKeysMap {
-		uniqueKeys = append(uniqueKeys, k)
+		fmt.Printf("Scanned %d records from trace\n", keySet.records)
+	} else {
+		fmt.Printf("Streaming trace file: %s\n", traceFile)
+		keySet = newTraceKeySet(false)
 	}
 
-	fmt.Printf("Found %d unique keys in trace\n", len(uniqueKeys))
-
-	// Count operations by type
-	readCount := 0
-	writeCount := 0
-	deleteCount := 0
-	otherCount := 0
-	for _, r := range records {
-		switch r.operation {
-		case "get", "gets":
-			readCount++
-		case "set", "add", "replace", "cas", "append", "prepend":
-			writeCount++
-		case "delete":
-			deleteCount++
-		default:
-			otherCount++
-		}
+	if keySet.records > 0 {
+		fmt.Printf("Found %d unique keys in trace\n", len(keySet.keys))
+		keySet.printBreakdown()
 	}
-	fmt.Printf("Operation breakdown: reads=%d (%.1f%%), writes=%d (%.1f%%), deletes=%d, other=%d\n",
-		readCount, float64(readCount)*100/float64(len(records)),
-		writeCount, float64(writeCount)*100/float64(len(records)),
-		deleteCount, otherCount)
 
 	readMode := strings.ToLower(p.GetString(TraceReadMode, TraceReadModeDefault))
 	if readMode != "read" && readMode != "skip" && readMode != "write" {
@@ -587,12 +795,39 @@ func (traceWorkloadCreator) Create(p *properties.Properties) (ycsb.Workload, err
 	}
 	fmt.Printf("Read mode: %s\n", readMode)
 
+	replayMode := strings.ToLower(p.GetString(TraceReplayMode, TraceReplayModeDefault))
+	if replayMode != traceReplayClosed && replayMode != traceReplayTimestamp {
+		return nil, fmt.Errorf("invalid trace.replaymode '%s', must be 'closed' or 'timestamp'", replayMode)
+	}
+	speedup := p.GetFloat64(TraceSpeedup, TraceSpeedupDefault)
+	if speedup <= 0 {
+		return nil, fmt.Errorf("invalid trace.speedup %v, must be positive", speedup)
+	}
+
+	// Timestamps have one-second resolution, so a pass over the trace lasts
+	// until the end of the second of its last record.
+	traceStart := keySet.minTimestamp
+	traceSpan := keySet.maxTimestamp - keySet.minTimestamp + 1
+	if replayMode == traceReplayTimestamp && !streaming {
+		fmt.Printf("Replay mode: timestamp (trace span %.0fs, speedup %.2fx)\n", traceSpan, speedup)
+	} else if replayMode == traceReplayTimestamp {
+		fmt.Printf("Replay mode: timestamp (speedup %.2fx)\n", speedup)
+	} else {
+		fmt.Printf("Replay mode: %s\n", replayMode)
+	}
+
+	partitionMode := strings.ToLower(p.GetString(TracePartition, TracePartitionDefault))
+	if partitionMode != tracePartitionNone && partitionMode != tracePartitionClient {
+		return nil, fmt.Errorf("invalid trace.partition '%s', must be 'none' or 'client'", partitionMode)
+	}
+	fmt.Printf("Partition mode: %s\n", partitionMode)
+
 	// Pre-compute deterministic values per key
 	var deterministicCache map[string][]byte
 	if deterministicValues {
-		deterministicCache = make(map[string][]byte, len(uniqueKeys))
-		for _, key := range uniqueKeys {
-			size := valueSizes[key]
+		deterministicCache = make(map[string][]byte, len(keySet.keys))
+		for _, key := range keySet.keys {
+			size := keySet.valueSizes[key]
 			if size <= 0 {
 				size = 100
 			}
@@ -608,14 +843,27 @@ func (traceWorkloadCreator) Create(p *properties.Properties) (ycsb.Workload, err
 		loopReplay:          p.GetBool(TraceLoopReplay, TraceLoopReplayDefault),
 		readMode:            readMode,
 		writeValueSize:      int(p.GetInt64(TraceWriteValueSize, TraceWriteValueSizeDefault)),
+		honorTTL:            p.GetBool(TraceHonorTTL, TraceHonorTTLDefault),
 		deterministicValues: deterministicValues,
 		deterministicCache:  deterministicCache,
+		traceFile:           traceFile,
+		traceFormat:         traceFormat,
+		maxRecords:          maxRecords,
+		streaming:           streaming,
+		prefetch:            p.GetInt(TracePrefetch, TracePrefetchDefault),
 		records:             records,
 		numRecords:          int64(len(records)),
-		uniqueKeys:          uniqueKeys,
-		keySizes:            keySizes,
-		valueSizes:          valueSizes,
-		numUniqueKeys:       int64(len(uniqueKeys)),
+		replayMode:          replayMode,
+		speedup:             speedup,
+		traceStart:          traceStart,
+		traceSpan:           traceSpan,
+		partitionMode:       partitionMode,
+		uniqueKeys:          keySet.keys,
+		keySizes:            keySet.keySizes,
+		valueSizes:          keySet.valueSizes,
+		numUniqueKeys:       int64(len(keySet.keys)),
+		counterKeys:         keySet.counters,
+		learnCounters:       streaming && keySet.records == 0,
 	}
 
 	return w, nil
@@ -0,0 +1,55 @@
+package workload
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/magiconair/properties"
+	"github.com/pingcap/go-ycsb/pkg/prop"
+)
+
+// TestTraceWorkloadThreadCount runs phases with different thread counts on
+// the same client partitioned trace workload, as a schedule does.
+func TestTraceWorkloadThreadCount(t *testing.T) {
+	path := writeTestTrace(t, "trace.csv")
+	for _, streaming := range []string{"false", "true"} {
+		p := properties.LoadMap(map[string]string{
+			TraceFile:           path,
+			TracePartition:      tracePartitionClient,
+			TraceStreaming:      streaming,
+			TraceLoopReplay:     "false",
+			prop.DoTransactions: "true",
+		})
+		created, err := traceWorkloadCreator{}.Create(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := created.(*traceWorkload)
+
+		for _, threadCount := range []int{2, 3, 1} {
+			var records int64
+			var wg sync.WaitGroup
+			for i := 0; i < threadCount; i++ {
+				wg.Add(1)
+				go func(threadID int) {
+					defer wg.Done()
+					ctx := w.InitThread(context.Background(), threadID, threadCount)
+					defer w.CleanupThread(ctx)
+					for {
+						if _, _, ok := w.nextRecord(ctx); !ok {
+							return
+						}
+						atomic.AddInt64(&records, 1)
+					}
+				}(i)
+			}
+			wg.Wait()
+			if records != 5 {
+				t.Errorf("streaming %s, %d threads: want 5 records, got %d", streaming, threadCount, records)
+			}
+		}
+		w.Close()
+	}
+}
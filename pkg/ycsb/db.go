@@ -16,6 +16,7 @@ package ycsb
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/magiconair/properties"
 )
@@ -102,6 +103,70 @@ type AnalyzeDB interface {
 	Analyze(ctx context.Context, table string) error
 }
 
+// TTLDB is the interface for the DB that can write records which expire after a while.
+type TTLDB interface {
+	// InsertWithTTL inserts a record in the database which expires after ttl.
+	// table: The name of the table.
+	// key: The record key of the record to insert.
+	// values: A map of field/value pairs to insert in the record.
+	// ttl: The time after which the record expires.
+	InsertWithTTL(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) error
+
+	// UpdateWithTTL updates a record in the database and sets it to expire after ttl.
+	// table: The name of the table.
+	// key: The record key of the record to update.
+	// values: A map of field/value pairs to update in the record.
+	// ttl: The time after which the record expires.
+	UpdateWithTTL(ctx context.Context, table string, key string, values map[string][]byte, ttl time.Duration) error
+}
+
+// AtomicCounterDB is the interface for the DB that can increment a counter in place.
+// Counters are stored as decimal strings, a missing field counts as 0.
+type AtomicCounterDB interface {
+	// Increment atomically adds delta to a counter field and returns the new value.
+	// table: The name of the table.
+	// key: The record key of the record holding the counter.
+	// field: The field of the counter.
+	// delta: The amount to add, negative to decrement.
+	Increment(ctx context.Context, table string, key string, field string, delta int64) (int64, error)
+}
+
+// AppendDB is the interface for the DB that can extend a field value in place.
+type AppendDB interface {
+	// Append atomically appends data to the value of a field.
+	// table: The name of the table.
+	// key: The record key of the record to modify.
+	// field: The field to append to.
+	// data: The bytes to append.
+	Append(ctx context.Context, table string, key string, field string, data []byte) error
+
+	// Prepend atomically prepends data to the value of a field.
+	// table: The name of the table.
+	// key: The record key of the record to modify.
+	// field: The field to prepend to.
+	// data: The bytes to prepend.
+	Prepend(ctx context.Context, table string, key string, field string, data []byte) error
+}
+
+// CompareAndSwapDB is the interface for the DB that can conditionally write a field.
+type CompareAndSwapDB interface {
+	// CompareAndSwap atomically sets a field to value if its current value equals
+	// expected, and reports whether it did. A missing field matches an empty expected
+	// value. A value mismatch is not an error.
+	// table: The name of the table.
+	// key: The record key of the record to modify.
+	// field: The field to compare and write.
+	// expected: The value the field must have for the write to happen.
+	// value: The new value of the field.
+	CompareAndSwap(ctx context.Context, table string, key string, field string, expected []byte, value []byte) (bool, error)
+}
+
+// StatsDB is the interface for the DB that can report server-side statistics.
+type StatsDB interface {
+	// Stats returns statistics of the database, such as cache hits, by name.
+	Stats(ctx context.Context) (map[string]interface{}, error)
+}
+
 var dbCreators = map[string]DBCreator{}
 
 // RegisterDBCreator registers a creator for the database